@@ -0,0 +1,249 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-kivik/couchdb/v4/chttp"
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// IndexAdvisor examines the query plans of Mango queries, to find queries
+// which are not served well by an index.
+//
+// Example, as a CI check:
+//
+//	advisor := &couchdb.IndexAdvisor{DB: client.DB("users")}
+//	advice, err := advisor.Advise(ctx, query)
+//	if err != nil {
+//	    t.Fatal(err)
+//	}
+//	if advice.NeedsIndex() {
+//	    t.Errorf("query needs an index: %v", advice.Index)
+//	}
+type IndexAdvisor struct {
+	// DB is the database against which queries are explained.
+	DB *kivik.DB
+
+	// Create, if true, creates the suggested index, if any.
+	Create bool
+
+	// DDoc and Name are passed to CreateIndex when creating an index. If
+	// empty, the server generates them.
+	DDoc, Name string
+}
+
+// IndexAdvice is the result of examining a query plan.
+type IndexAdvice struct {
+	// Plan is the query plan returned by Explain, or nil if the query sorts
+	// by fields which no index covers, in which case the server refuses to
+	// run it.
+	Plan *kivik.QueryPlan
+
+	// FullScan is true if no index matched the query, so that every document
+	// in the database must be examined.
+	FullScan bool
+
+	// UnusedSortFields lists the sort fields of the query which are not
+	// covered by the selected index, or all of them, if Plan is nil.
+	UnusedSortFields []string
+
+	// Index is the suggested index definition, in the form accepted by
+	// [github.com/go-kivik/kivik/v4.DB.CreateIndex], or nil if no index is
+	// needed.
+	Index map[string]interface{}
+
+	// Created is true if the suggested index was created.
+	Created bool
+}
+
+// NeedsIndex returns true if the query would benefit from a new index.
+func (a *IndexAdvice) NeedsIndex() bool {
+	return a.Index != nil
+}
+
+// Advise explains query, which may be any value accepted by
+// [github.com/go-kivik/kivik/v4.DB.Explain], and reports how well it is
+// served by the available indexes. options are passed to Explain, and to
+// CreateIndex, so OptionPartition may be used for partitioned databases.
+func (a *IndexAdvisor) Advise(ctx context.Context, query interface{}, options ...kivik.Options) (*IndexAdvice, error) {
+	plan, err := a.DB.Explain(ctx, query, options...)
+	if err != nil && !isNoUsableIndex(err) {
+		return nil, err
+	}
+	q, err := findQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	advice := &IndexAdvice{Plan: plan}
+	sortFields := querySortFields(q["sort"])
+	if plan == nil {
+		// No index covers the sort, so the server refused to plan the query.
+		advice.UnusedSortFields = sortFields
+	} else {
+		advice.FullScan = planIndexType(plan) == "special"
+		indexFields := planIndexFields(plan)
+		for _, field := range sortFields {
+			if !indexFields[field] {
+				advice.UnusedSortFields = append(advice.UnusedSortFields, field)
+			}
+		}
+	}
+	if !advice.FullScan && len(advice.UnusedSortFields) == 0 {
+		return advice, nil
+	}
+	fields := suggestIndexFields(q["selector"], sortFields)
+	if len(fields) == 0 {
+		// An empty selector can't be served by any index.
+		return advice, nil
+	}
+	advice.Index = map[string]interface{}{"fields": fields}
+	if a.Create {
+		if err := a.DB.CreateIndex(ctx, a.DDoc, a.Name, advice.Index, options...); err != nil {
+			return advice, err
+		}
+		advice.Created = true
+	}
+	return advice, nil
+}
+
+// isNoUsableIndex returns true if err is the server's response to a query
+// whose sort is not covered by any index.
+func isNoUsableIndex(err error) bool {
+	var herr *chttp.HTTPError
+	return errors.As(err, &herr) && herr.HTTPStatus() == http.StatusBadRequest && herr.Err == "no_usable_index"
+}
+
+func planIndexType(plan *kivik.QueryPlan) string {
+	t, _ := plan.Index["type"].(string)
+	return t
+}
+
+// planIndexFields returns the fields of the index selected by plan.
+func planIndexFields(plan *kivik.QueryPlan) map[string]bool {
+	result := map[string]bool{}
+	def, _ := plan.Index["def"].(map[string]interface{})
+	fields, _ := def["fields"].([]interface{})
+	for _, field := range fields {
+		for _, name := range sortFieldNames(field) {
+			result[name] = true
+		}
+	}
+	return result
+}
+
+// querySortFields returns the field names of a Mango sort specification,
+// which may contain both bare field names, and {"field": "direction"} objects.
+func querySortFields(sortSpec interface{}) []string {
+	list, _ := sortSpec.([]interface{})
+	var result []string
+	for _, field := range list {
+		result = append(result, sortFieldNames(field)...)
+	}
+	return result
+}
+
+func sortFieldNames(field interface{}) []string {
+	switch t := field.(type) {
+	case string:
+		return []string{t}
+	case map[string]interface{}:
+		names := make([]string, 0, len(t))
+		for name := range t {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	return nil
+}
+
+// suggestIndexFields builds the field list of an index to serve a query:
+// fields the selector matches by equality, then the sort fields, and then
+// fields the selector matches by other conditions. Fields appearing only
+// within $or, $nor or $not can't be used by an index, and are omitted.
+func suggestIndexFields(selector interface{}, sortFields []string) []string {
+	var eq, other []string
+	collectSelectorFields(selector, "", &eq, &other)
+	seen := make(map[string]bool, len(sortFields))
+	for _, field := range sortFields {
+		seen[field] = true
+	}
+	var result []string
+	add := func(fields []string) {
+		sort.Strings(fields)
+		for _, field := range fields {
+			if !seen[field] {
+				seen[field] = true
+				result = append(result, field)
+			}
+		}
+	}
+	add(eq)
+	result = append(result, sortFields...)
+	add(other)
+	return result
+}
+
+// collectSelectorFields walks a Mango selector, appending fields matched by
+// equality to eq, and other fields to other.
+func collectSelectorFields(selector interface{}, prefix string, eq, other *[]string) {
+	obj, ok := selector.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, value := range obj {
+		if key == "$and" {
+			list, _ := value.([]interface{})
+			for _, sub := range list {
+				collectSelectorFields(sub, prefix, eq, other)
+			}
+			continue
+		}
+		if strings.HasPrefix(key, "$") {
+			continue
+		}
+		field := key
+		if prefix != "" {
+			field = prefix + "." + key
+		}
+		cond, isObj := value.(map[string]interface{})
+		if !isObj {
+			*eq = append(*eq, field)
+			continue
+		}
+		if hasOperators(cond) {
+			if _, isEq := cond["$eq"]; isEq && len(cond) == 1 {
+				*eq = append(*eq, field)
+			} else {
+				*other = append(*other, field)
+			}
+			continue
+		}
+		collectSelectorFields(cond, field, eq, other)
+	}
+}
+
+func hasOperators(obj map[string]interface{}) bool {
+	for key := range obj {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,185 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/couchdb/v4/mango"
+)
+
+func TestIndexAdvisorAdvise(t *testing.T) {
+	const allDocsIndex = `{"ddoc":null,"name":"_all_docs","type":"special","def":{"fields":[{"_id":"asc"}]}}`
+	explainResponse := func(index string) func(*http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/testdb/_explain" {
+				return nil, errors.New("unexpected request: " + req.Method + " " + req.URL.Path)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       Body(`{"dbname":"testdb","index":` + index + `,"selector":{},"opts":{}}`),
+			}, nil
+		}
+	}
+	noUsableIndex := func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusBadRequest,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			ContentLength: -1,
+			Body:          Body(`{"error":"no_usable_index","reason":"No index exists for this sort, try indexing by the sort fields."}`),
+			Request:       req,
+		}, nil
+	}
+	type tst struct {
+		fn       func(*http.Request) (*http.Response, error)
+		create   bool
+		query    interface{}
+		fullScan bool
+		unused   []string
+		index    interface{}
+		created  bool
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("explain error", tst{
+		fn: func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("explain failed")
+		},
+		query:  `{"selector":{"type":"user"}}`,
+		status: http.StatusBadGateway,
+		err:    "explain failed",
+	})
+	tests.Add("index used", tst{
+		fn:    explainResponse(`{"ddoc":"_design/x","name":"by-type","type":"json","def":{"fields":[{"type":"asc"}]}}`),
+		query: `{"selector":{"type":"user"}}`,
+	})
+	tests.Add("full scan", tst{
+		fn:       explainResponse(allDocsIndex),
+		query:    `{"selector":{"type":"user","age":{"$gt":21},"$or":[{"x":1},{"y":2}]}}`,
+		fullScan: true,
+		index:    map[string]interface{}{"fields": []string{"type", "age"}},
+	})
+	tests.Add("full scan, empty selector", tst{
+		fn:       explainResponse(allDocsIndex),
+		query:    `{"selector":{}}`,
+		fullScan: true,
+	})
+	tests.Add("no usable index for sort", tst{
+		fn:     noUsableIndex,
+		query:  `{"selector":{"type":"user","age":{"$gt":21}},"sort":["type",{"name":"desc"}]}`,
+		unused: []string{"type", "name"},
+		index:  map[string]interface{}{"fields": []string{"type", "name", "age"}},
+	})
+	tests.Add("other bad request", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode:    http.StatusBadRequest,
+				Header:        http.Header{"Content-Type": {"application/json"}},
+				ContentLength: -1,
+				Body:          Body(`{"error":"invalid_selector","reason":"Bad selector"}`),
+				Request:       req,
+			}, nil
+		},
+		query:  `{"selector":{"type":"user"}}`,
+		status: http.StatusBadRequest,
+		err:    "Bad Request: Bad selector",
+	})
+	tests.Add("typed query", tst{
+		fn: noUsableIndex,
+		query: &mango.Query{
+			Selector: mango.And(mango.Eq("type", "user"), mango.Gte("profile.age", 21)),
+			Sort:     []mango.SortField{{Field: "profile.age"}},
+		},
+		unused: []string{"profile.age"},
+		index:  map[string]interface{}{"fields": []string{"type", "profile.age"}},
+	})
+	tests.Add("nested fields", tst{
+		fn:       explainResponse(allDocsIndex),
+		query:    `{"selector":{"address":{"city":"Paris","zip":{"$eq":"75001"}}}}`,
+		fullScan: true,
+		index:    map[string]interface{}{"fields": []string{"address.city", "address.zip"}},
+	})
+	tests.Add("create index", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/testdb/_explain" {
+				return explainResponse(allDocsIndex)(req)
+			}
+			if req.Method != http.MethodPost || req.URL.Path != "/testdb/_index" {
+				return nil, errors.New("unexpected request: " + req.Method + " " + req.URL.Path)
+			}
+			body, err := io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			if d := testy.DiffJSON([]byte(`{"ddoc":"advisor","name":"by-type","index":{"fields":["type"]}}`), body); d != nil {
+				return nil, errors.New(d.String())
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       Body(`{"result":"created"}`),
+			}, nil
+		},
+		create:   true,
+		query:    map[string]interface{}{"selector": map[string]interface{}{"type": "user"}},
+		fullScan: true,
+		index:    map[string]interface{}{"fields": []string{"type"}},
+		created:  true,
+	})
+	tests.Add("create index failure", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/testdb/_explain" {
+				return explainResponse(allDocsIndex)(req)
+			}
+			return nil, errors.New("create failed")
+		},
+		create: true,
+		query:  `{"selector":{"type":"user"}}`,
+		status: http.StatusBadGateway,
+		err:    "create failed",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		advisor := &IndexAdvisor{
+			DB:     newTestKivikDB(t, test.fn),
+			Create: test.create,
+			DDoc:   "advisor",
+			Name:   "by-type",
+		}
+		advice, err := advisor.Advise(context.Background(), test.query)
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		if advice.FullScan != test.fullScan {
+			t.Errorf("Unexpected FullScan: %t", advice.FullScan)
+		}
+		if d := testy.DiffInterface(test.unused, advice.UnusedSortFields); d != nil {
+			t.Errorf("Unexpected unused sort fields:\n%s", d)
+		}
+		if d := testy.DiffAsJSON(test.index, advice.Index); d != nil {
+			t.Errorf("Unexpected index:\n%s", d)
+		}
+		if advice.NeedsIndex() != (test.index != nil) {
+			t.Errorf("Unexpected NeedsIndex: %t", advice.NeedsIndex())
+		}
+		if advice.Created != test.created {
+			t.Errorf("Unexpected Created: %t", advice.Created)
+		}
+	})
+}
@@ -13,9 +13,17 @@
 package couchdb
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5" // nolint:gosec
+	"encoding/base64"
 	"errors"
+	"fmt"
+	"hash"
+	"io"
 	"net/http"
+	"strings"
 
 	"github.com/go-kivik/couchdb/v4/chttp"
 	kivik "github.com/go-kivik/kivik/v4"
@@ -36,6 +44,10 @@ func (d *db) PutAttachment(ctx context.Context, docID string, att *driver.Attach
 		return "", missingArg("att.Content")
 	}
 
+	verify, err := boolOption(options, OptionVerifyDigest)
+	if err != nil {
+		return "", err
+	}
 	opts, err := chttp.NewOptions(options)
 	if err != nil {
 		return "", err
@@ -49,6 +61,27 @@ func (d *db) PutAttachment(ctx context.Context, docID string, att *driver.Attach
 		Rev string `json:"rev"`
 	}
 	opts.Body = att.Content
+	if verify {
+		md5sum, content, err := attachmentMD5(att)
+		if err != nil {
+			return "", err
+		}
+		opts.Body = content
+		opts.Header = http.Header{}
+		opts.Header.Set("Content-MD5", md5sum)
+		// CouchDB checks the digest against the bytes as sent, so they
+		// must not be compressed in transit.
+		opts.NoGzip = true
+	}
+	if att.ContentEncoding != "" {
+		// Pre-encoded content is stored as-is by CouchDB, and must not be
+		// compressed a second time.
+		if opts.Header == nil {
+			opts.Header = http.Header{}
+		}
+		opts.Header.Set("Content-Encoding", att.ContentEncoding)
+		opts.NoGzip = true
+	}
 	opts.ContentType = att.ContentType
 	opts.Query = query
 	err = d.Client.DoJSON(ctx, http.MethodPut, d.path(chttp.EncodeDocID(docID)+"/"+att.Filename), opts, &response)
@@ -59,7 +92,11 @@ func (d *db) PutAttachment(ctx context.Context, docID string, att *driver.Attach
 }
 
 func (d *db) GetAttachmentMeta(ctx context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
-	resp, err := d.fetchAttachment(ctx, http.MethodHead, docID, filename, options)
+	enc, err := attachmentEncoding(options)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.fetchAttachment(ctx, http.MethodHead, docID, filename, enc, options)
 	if err != nil {
 		return nil, err
 	}
@@ -67,15 +104,169 @@ func (d *db) GetAttachmentMeta(ctx context.Context, docID, filename string, opti
 	return att, err
 }
 
+// attachmentMD5 returns the base64-encoded MD5 sum of att's content, and a
+// reader for the content. A digest already set on att is used as-is. Otherwise
+// seekable content is read once to compute the sum, and then rewound, while
+// any other content is read into memory.
+func attachmentMD5(att *driver.Attachment) (string, io.ReadCloser, error) {
+	if att.Digest != "" {
+		md5sum := strings.TrimPrefix(att.Digest, "md5-")
+		if sum, err := base64.StdEncoding.DecodeString(md5sum); err != nil || len(sum) != md5.Size {
+			return "", nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: invalid MD5 digest: %s", att.Digest)}
+		}
+		return md5sum, att.Content, nil
+	}
+	h := md5.New() // nolint:gosec
+	if seeker, ok := att.Content.(io.Seeker); ok {
+		start, err := seeker.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := io.Copy(h, att.Content); err != nil {
+			return "", nil, err
+		}
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return "", nil, err
+		}
+		return base64.StdEncoding.EncodeToString(h.Sum(nil)), att.Content, nil
+	}
+	content, err := io.ReadAll(att.Content)
+	if err != nil {
+		return "", nil, err
+	}
+	_, _ = h.Write(content)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)), struct {
+		io.Reader
+		io.Closer
+	}{bytes.NewReader(content), att.Content}, nil
+}
+
 func (d *db) GetAttachment(ctx context.Context, docID, filename string, options map[string]interface{}) (*driver.Attachment, error) {
-	resp, err := d.fetchAttachment(ctx, http.MethodGet, docID, filename, options)
+	verify, err := boolOption(options, OptionVerifyDigest)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := attachmentEncoding(options)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.fetchAttachment(ctx, http.MethodGet, docID, filename, enc, options)
+	if err != nil {
+		return nil, err
+	}
+	att, err := decodeAttachment(resp)
+	if err != nil {
+		return nil, err
+	}
+	if enc == attEncodingDecode && att.ContentEncoding == "gzip" {
+		content, err := newGzipReadCloser(att.Content)
+		if err != nil {
+			_ = att.Content.Close()
+			return nil, &kivik.Error{Status: http.StatusBadGateway, Err: err}
+		}
+		att.Content = content
+		att.ContentEncoding = ""
+		att.EncodedLength = 0
+	}
+	// Partial or re-encoded content cannot be checked against the digest of
+	// the stored attachment.
+	if verify && resp.StatusCode == http.StatusOK && resp.Header.Get("Content-Encoding") == "" && !resp.Uncompressed {
+		att.Content = &digestReader{ReadCloser: att.Content, digest: att.Digest, h: md5.New()} // nolint:gosec
+	}
+	return att, nil
+}
+
+// digestReader computes the MD5 sum of the content read through it, and
+// returns an error in place of io.EOF if the sum does not match digest.
+type digestReader struct {
+	io.ReadCloser
+	digest string
+	h      hash.Hash
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	_, _ = r.h.Write(p[:n])
+	if err == io.EOF {
+		if verr := verifyDigest(r.digest, r.h); verr != nil {
+			return n, verr
+		}
+	}
+	return n, err
+}
+
+// verifyDigest compares the MD5 sum in h to digest, which may be either the
+// bare base64-encoded sum, or prefixed with "md5-".
+func verifyDigest(digest string, h hash.Hash) error {
+	if !strings.HasPrefix(digest, "md5-") && strings.Contains(digest, "-") {
+		// Not an MD5 digest; nothing to verify.
+		return nil
+	}
+	want := strings.TrimPrefix(digest, "md5-")
+	if got := base64.StdEncoding.EncodeToString(h.Sum(nil)); got != want {
+		return &kivik.Error{Status: http.StatusBadGateway, Err: fmt.Errorf("kivik: attachment digest mismatch: expected %s, got %s", want, got)}
+	}
+	return nil
+}
+
+// attEncoding selects how attachments stored with a content encoding, such as
+// gzip, are transferred.
+type attEncoding int
+
+const (
+	// attEncodingDefault leaves content negotiation to the HTTP transport.
+	attEncodingDefault attEncoding = iota
+	// attEncodingRaw requests attachments in their stored encoding, and
+	// returns them undecoded.
+	attEncodingRaw
+	// attEncodingDecode requests attachments in their stored encoding, and
+	// decodes them on the client.
+	attEncodingDecode
+)
+
+// attachmentEncoding extracts OptionRawAttachmentEncoding and
+// OptionCompressedAttachments from opts.
+func attachmentEncoding(opts map[string]interface{}) (attEncoding, error) {
+	raw, err := boolOption(opts, OptionRawAttachmentEncoding)
+	if err != nil {
+		return attEncodingDefault, err
+	}
+	compressed, err := boolOption(opts, OptionCompressedAttachments)
+	if err != nil {
+		return attEncodingDefault, err
+	}
+	switch {
+	case raw && compressed:
+		return attEncodingDefault, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: options '%s' and '%s' are mutually exclusive", OptionRawAttachmentEncoding, OptionCompressedAttachments)}
+	case raw:
+		return attEncodingRaw, nil
+	case compressed:
+		return attEncodingDecode, nil
+	}
+	return attEncodingDefault, nil
+}
+
+// gzipReadCloser decodes gzip content, and closes the underlying stream when
+// closed.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func newGzipReadCloser(body io.ReadCloser) (*gzipReadCloser, error) {
+	gz, err := gzip.NewReader(body)
 	if err != nil {
 		return nil, err
 	}
-	return decodeAttachment(resp)
+	return &gzipReadCloser{Reader: gz, body: body}, nil
+}
+
+func (r *gzipReadCloser) Close() error {
+	_ = r.Reader.Close()
+	return r.body.Close()
 }
 
-func (d *db) fetchAttachment(ctx context.Context, method, docID, filename string, options map[string]interface{}) (*http.Response, error) {
+func (d *db) fetchAttachment(ctx context.Context, method, docID, filename string, enc attEncoding, options map[string]interface{}) (*http.Response, error) {
 	if method == "" {
 		return nil, errors.New("method required")
 	}
@@ -89,6 +280,19 @@ func (d *db) fetchAttachment(ctx context.Context, method, docID, filename string
 	if err != nil {
 		return nil, err
 	}
+	byteRange, err := rangeHeader(options)
+	if err != nil {
+		return nil, err
+	}
+	opts.Header = http.Header{}
+	if byteRange != "" {
+		opts.Header.Set("Range", byteRange)
+	}
+	if enc != attEncodingDefault {
+		// Setting Accept-Encoding explicitly prevents the HTTP transport from
+		// decoding the response itself.
+		opts.Header.Set("Accept-Encoding", "gzip")
+	}
 
 	opts.Query, err = optionsToParams(options)
 	if err != nil {
@@ -101,6 +305,32 @@ func (d *db) fetchAttachment(ctx context.Context, method, docID, filename string
 	return resp, chttp.ResponseError(resp)
 }
 
+// rangeHeader builds the value of the Range header from OptionRangeOffset and
+// OptionRangeLength, or returns an empty string if neither is set.
+func rangeHeader(options map[string]interface{}) (string, error) {
+	offset, hasOffset, err := int64Option(options, OptionRangeOffset)
+	if err != nil {
+		return "", err
+	}
+	length, hasLength, err := int64Option(options, OptionRangeLength)
+	if err != nil {
+		return "", err
+	}
+	if offset < 0 {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: range offset must not be negative")}
+	}
+	if hasLength && length <= 0 {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: range length must be positive")}
+	}
+	switch {
+	case hasLength:
+		return fmt.Sprintf("bytes=%d-%d", offset, offset+length-1), nil
+	case hasOffset:
+		return fmt.Sprintf("bytes=%d-", offset), nil
+	}
+	return "", nil
+}
+
 func decodeAttachment(resp *http.Response) (*driver.Attachment, error) {
 	cType, err := getContentType(resp)
 	if err != nil {
@@ -111,12 +341,18 @@ func decodeAttachment(resp *http.Response) (*driver.Attachment, error) {
 		return nil, err
 	}
 
-	return &driver.Attachment{
+	att := &driver.Attachment{
 		ContentType: cType,
 		Digest:      digest,
 		Size:        resp.ContentLength,
 		Content:     resp.Body,
-	}, nil
+	}
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		att.ContentEncoding = encoding
+		att.EncodedLength = resp.ContentLength
+		att.Size = -1
+	}
+	return att, nil
 }
 
 func getContentType(resp *http.Response) (string, error) {
@@ -19,6 +19,7 @@ import (
 	"io"
 	"mime"
 	"net/http"
+	"os"
 	"strings"
 	"testing"
 
@@ -225,6 +226,163 @@ func TestPutAttachment(t *testing.T) {
 			status: http.StatusBadRequest,
 			err:    "kivik: option 'X-Couch-Full-Commit' must be bool, not int",
 		},
+		func() paoTest {
+			verifyMD5 := func(want string) func(*http.Request) (*http.Response, error) {
+				return func(req *http.Request) (*http.Response, error) {
+					body, err := io.ReadAll(req.Body)
+					if err != nil {
+						return nil, err
+					}
+					if string(body) != "x\n" {
+						return nil, fmt.Errorf("Unexpected body: %q", string(body))
+					}
+					if ce := req.Header.Get("Content-Encoding"); ce != "" {
+						return nil, fmt.Errorf("Unexpected Content-Encoding: %s", ce)
+					}
+					if md5sum := req.Header.Get("Content-MD5"); md5sum != want {
+						return nil, fmt.Errorf("Unexpected Content-MD5: %s", md5sum)
+					}
+					return nil, errors.New("success")
+				}
+			}
+			return paoTest{
+				name: "verify digest, buffered content",
+				db:   newCustomDB(verifyMD5("QBsw47i11iljWlxhPNt5GQ==")),
+				id:   "foo",
+				att: &driver.Attachment{
+					Filename:    "foo.txt",
+					ContentType: "text/plain",
+					Content:     Body("x"),
+				},
+				options: map[string]interface{}{
+					"rev":              "1-xxx",
+					OptionVerifyDigest: true,
+				},
+				status: http.StatusBadGateway,
+				err:    "success",
+			}
+		}(),
+		func() paoTest {
+			f, err := os.CreateTemp(t.TempDir(), "att-*")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.WriteString("x\n"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				t.Fatal(err)
+			}
+			return paoTest{
+				name: "verify digest, seekable content",
+				db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+					body, err := io.ReadAll(req.Body)
+					if err != nil {
+						return nil, err
+					}
+					if string(body) != "x\n" {
+						return nil, fmt.Errorf("Unexpected body: %q", string(body))
+					}
+					if md5sum := req.Header.Get("Content-MD5"); md5sum != "QBsw47i11iljWlxhPNt5GQ==" {
+						return nil, fmt.Errorf("Unexpected Content-MD5: %s", md5sum)
+					}
+					return nil, errors.New("success")
+				}),
+				id: "foo",
+				att: &driver.Attachment{
+					Filename:    "foo.txt",
+					ContentType: "text/plain",
+					Content:     f,
+				},
+				options: map[string]interface{}{
+					"rev":              "1-xxx",
+					OptionVerifyDigest: true,
+				},
+				status: http.StatusBadGateway,
+				err:    "success",
+			}
+		}(),
+		{
+			name: "verify digest, supplied digest",
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				if md5sum := req.Header.Get("Content-MD5"); md5sum != "ndTkYSaMgDT1yFZOFVxnpg==" {
+					return nil, fmt.Errorf("Unexpected Content-MD5: %s", md5sum)
+				}
+				return nil, errors.New("success")
+			}),
+			id: "foo",
+			att: &driver.Attachment{
+				Filename:    "foo.txt",
+				ContentType: "text/plain",
+				Digest:      "md5-ndTkYSaMgDT1yFZOFVxnpg==",
+				Content:     Body("x"),
+			},
+			options: map[string]interface{}{
+				"rev":              "1-xxx",
+				OptionVerifyDigest: true,
+			},
+			status: http.StatusBadGateway,
+			err:    "success",
+		},
+		{
+			name: "pre-encoded content",
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				body, err := io.ReadAll(req.Body)
+				if err != nil {
+					return nil, err
+				}
+				if string(body) != gzipped("x") {
+					return nil, fmt.Errorf("Unexpected body: %q", string(body))
+				}
+				if ce := req.Header.Get("Content-Encoding"); ce != "gzip" {
+					return nil, fmt.Errorf("Unexpected Content-Encoding: %s", ce)
+				}
+				return nil, errors.New("success")
+			}),
+			id: "foo",
+			att: &driver.Attachment{
+				Filename:        "foo.txt",
+				ContentType:     "text/plain",
+				ContentEncoding: "gzip",
+				Content:         io.NopCloser(strings.NewReader(gzipped("x"))),
+			},
+			options: map[string]interface{}{"rev": "1-xxx"},
+			status:  http.StatusBadGateway,
+			err:     "success",
+		},
+		{
+			name: "verify digest, invalid supplied digest",
+			db:   &db{},
+			id:   "foo",
+			att: &driver.Attachment{
+				Filename:    "foo.txt",
+				ContentType: "text/plain",
+				Digest:      "sha-xxx",
+				Content:     Body("x"),
+			},
+			options: map[string]interface{}{
+				"rev":              "1-xxx",
+				OptionVerifyDigest: true,
+			},
+			status: http.StatusBadRequest,
+			err:    "kivik: invalid MD5 digest: sha-xxx",
+		},
+		{
+			name: "invalid verify digest type",
+			db:   &db{},
+			id:   "foo",
+			att: &driver.Attachment{
+				Filename:    "foo.txt",
+				ContentType: "text/plain",
+				Content:     Body("x"),
+			},
+			options: map[string]interface{}{
+				"rev":              "1-xxx",
+				OptionVerifyDigest: "yes",
+			},
+			status: http.StatusBadRequest,
+			err:    "kivik: option 'kivik:verify-digest' must be bool, not string",
+		},
 		func() paoTest {
 			body := &closer{Reader: strings.NewReader("x")}
 			return paoTest{
@@ -377,6 +535,7 @@ func TestGetAttachment(t *testing.T) {
 		content  string
 		status   int
 		err      string
+		readErr  string
 	}{
 		{
 			name:     "network error",
@@ -411,15 +570,125 @@ func TestGetAttachment(t *testing.T) {
 			},
 			content: "Hello, world!",
 		},
+		{
+			name:     "verify digest",
+			id:       "foo",
+			filename: "foo.txt",
+			options:  map[string]interface{}{OptionVerifyDigest: true},
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Header: http.Header{
+						"ETag":         {`"bNNVbesNpUvKBgtMOUeYOQ=="`},
+						"Content-Type": {"text/plain"},
+					},
+					Body: io.NopCloser(strings.NewReader("Hello, world!")),
+				}, nil
+			}),
+			expected: &driver.Attachment{
+				ContentType: "text/plain",
+				Digest:      "bNNVbesNpUvKBgtMOUeYOQ==",
+			},
+			content: "Hello, world!",
+		},
+		{
+			name:     "verify digest mismatch",
+			id:       "foo",
+			filename: "foo.txt",
+			options:  map[string]interface{}{OptionVerifyDigest: true},
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: 200,
+					Header: http.Header{
+						"ETag":         {`"gSr8dSmynwAoomH7V6RVYw=="`},
+						"Content-Type": {"text/plain"},
+					},
+					Body: io.NopCloser(strings.NewReader("Hello, world!")),
+				}, nil
+			}),
+			readErr: "kivik: attachment digest mismatch: expected gSr8dSmynwAoomH7V6RVYw==, got bNNVbesNpUvKBgtMOUeYOQ==",
+		},
+		{
+			name:     "raw encoding",
+			id:       "foo",
+			filename: "foo.txt",
+			options:  map[string]interface{}{OptionRawAttachmentEncoding: true},
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				if ae := req.Header.Get("Accept-Encoding"); ae != "gzip" {
+					return nil, fmt.Errorf("Unexpected Accept-Encoding: %s", ae)
+				}
+				return &http.Response{
+					StatusCode: 200,
+					Header: http.Header{
+						"ETag":             {`"bNNVbesNpUvKBgtMOUeYOQ=="`},
+						"Content-Type":     {"text/plain"},
+						"Content-Encoding": {"gzip"},
+					},
+					ContentLength: int64(len(gzipped("Hello, world!"))),
+					Body:          io.NopCloser(strings.NewReader(gzipped("Hello, world!"))),
+				}, nil
+			}),
+			expected: &driver.Attachment{
+				ContentType:     "text/plain",
+				Digest:          "bNNVbesNpUvKBgtMOUeYOQ==",
+				Size:            -1,
+				ContentEncoding: "gzip",
+				EncodedLength:   int64(len(gzipped("Hello, world!"))),
+			},
+			content: gzipped("Hello, world!"),
+		},
+		{
+			name:     "compressed transfer",
+			id:       "foo",
+			filename: "foo.txt",
+			options:  map[string]interface{}{OptionCompressedAttachments: true},
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				if ae := req.Header.Get("Accept-Encoding"); ae != "gzip" {
+					return nil, fmt.Errorf("Unexpected Accept-Encoding: %s", ae)
+				}
+				return &http.Response{
+					StatusCode: 200,
+					Header: http.Header{
+						"ETag":             {`"bNNVbesNpUvKBgtMOUeYOQ=="`},
+						"Content-Type":     {"text/plain"},
+						"Content-Encoding": {"gzip"},
+					},
+					ContentLength: int64(len(gzipped("Hello, world!"))),
+					Body:          io.NopCloser(strings.NewReader(gzipped("Hello, world!"))),
+				}, nil
+			}),
+			expected: &driver.Attachment{
+				ContentType: "text/plain",
+				Digest:      "bNNVbesNpUvKBgtMOUeYOQ==",
+				Size:        -1,
+			},
+			content: "Hello, world!",
+		},
+		{
+			name:     "invalid encoding option type",
+			id:       "foo",
+			filename: "foo.txt",
+			options:  map[string]interface{}{OptionCompressedAttachments: "yes"},
+			db:       &db{},
+			status:   http.StatusBadRequest,
+			err:      "kivik: option 'kivik:compressed-attachments' must be bool, not string",
+		},
+		{
+			name:     "invalid verify digest type",
+			id:       "foo",
+			filename: "foo.txt",
+			options:  map[string]interface{}{OptionVerifyDigest: 1},
+			db:       &db{},
+			status:   http.StatusBadRequest,
+			err:      "kivik: option 'kivik:verify-digest' must be bool, not int",
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			att, err := test.db.GetAttachment(context.Background(), test.id, test.filename, test.options)
 			testy.StatusErrorRE(t, test.err, test.status, err)
 			fileContent, err := io.ReadAll(att.Content)
-			if err != nil {
-				t.Fatal(err)
-			}
+			testy.Error(t, test.readErr, err)
 			if d := testy.DiffText(test.content, string(fileContent)); d != nil {
 				t.Errorf("Unexpected content:\n%s", d)
 			}
@@ -520,6 +789,37 @@ func TestFetchAttachment(t *testing.T) {
 			status:   http.StatusBadGateway,
 			err:      "success",
 		},
+		{
+			name: "range",
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				if err := consume(req.Body); err != nil {
+					return nil, err
+				}
+				if rng := req.Header.Get("Range"); rng != "bytes=100-149" {
+					return nil, fmt.Errorf("Unexpected Range: %s", rng)
+				}
+				if q := req.URL.RawQuery; q != "" {
+					return nil, fmt.Errorf("Unexpected query: %s", q)
+				}
+				return nil, errors.New("success")
+			}),
+			method:   "GET",
+			id:       "foo",
+			filename: "foo.txt",
+			options:  map[string]interface{}{OptionRangeOffset: int64(100), OptionRangeLength: 50},
+			status:   http.StatusBadGateway,
+			err:      "success",
+		},
+		{
+			name:     "invalid range offset type",
+			db:       &db{},
+			method:   "GET",
+			id:       "foo",
+			filename: "foo.txt",
+			options:  map[string]interface{}{OptionRangeOffset: "100"},
+			status:   http.StatusBadRequest,
+			err:      "kivik: option 'kivik:range-offset' must be int or int64, not string",
+		},
 		{
 			name:     "invalid if-none-match type",
 			db:       &db{},
@@ -533,7 +833,7 @@ func TestFetchAttachment(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			resp, err := test.db.fetchAttachment(context.Background(), test.method, test.id, test.filename, test.options)
+			resp, err := test.db.fetchAttachment(context.Background(), test.method, test.id, test.filename, attEncodingDefault, test.options)
 			testy.StatusErrorRE(t, test.err, test.status, err)
 
 			if d := testy.DiffJSON(test.resp.Body, resp.Body); d != nil {
@@ -551,6 +851,47 @@ func TestFetchAttachment(t *testing.T) {
 	}
 }
 
+func TestRangeHeader(t *testing.T) {
+	type tst struct {
+		options  map[string]interface{}
+		expected string
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("no range", tst{})
+	tests.Add("offset", tst{
+		options:  map[string]interface{}{OptionRangeOffset: 1024},
+		expected: "bytes=1024-",
+	})
+	tests.Add("length", tst{
+		options:  map[string]interface{}{OptionRangeLength: int64(10)},
+		expected: "bytes=0-9",
+	})
+	tests.Add("offset and length", tst{
+		options:  map[string]interface{}{OptionRangeOffset: 10, OptionRangeLength: 10},
+		expected: "bytes=10-19",
+	})
+	tests.Add("negative offset", tst{
+		options: map[string]interface{}{OptionRangeOffset: -1},
+		status:  http.StatusBadRequest,
+		err:     "kivik: range offset must not be negative",
+	})
+	tests.Add("zero length", tst{
+		options: map[string]interface{}{OptionRangeLength: 0},
+		status:  http.StatusBadRequest,
+		err:     "kivik: range length must be positive",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		result, err := rangeHeader(test.options)
+		testy.StatusError(t, test.err, test.status, err)
+		if result != test.expected {
+			t.Errorf("Unexpected result: %s", result)
+		}
+	})
+}
+
 func TestDecodeAttachment(t *testing.T) {
 	tests := []struct {
 		name     string
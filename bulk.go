@@ -15,11 +15,9 @@ package couchdb
 import (
 	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 
 	"github.com/go-kivik/couchdb/v4/chttp"
-	kivik "github.com/go-kivik/kivik/v4"
 	"github.com/go-kivik/kivik/v4/driver"
 )
 
@@ -27,6 +25,22 @@ func (d *db) BulkDocs(ctx context.Context, docs []interface{}, options map[strin
 	if options == nil {
 		options = make(map[string]interface{})
 	}
+	newEdits, err := newEditsOption(options)
+	if err != nil {
+		return nil, err
+	}
+	var stored []driver.BulkResult
+	if !newEdits {
+		stored = make([]driver.BulkResult, len(docs))
+		for i, doc := range docs {
+			id, rev, err := validateRevisions(doc)
+			if err != nil {
+				return nil, err
+			}
+			stored[i] = driver.BulkResult{ID: id, Rev: rev}
+		}
+		docs = rawDocs(docs)
+	}
 	opts, err := chttp.NewOptions(options)
 	if err != nil {
 		return nil, err
@@ -58,6 +72,9 @@ func (d *db) BulkDocs(ctx context.Context, docs []interface{}, options map[strin
 	if err := chttp.DecodeJSON(resp, &temp); err != nil {
 		return nil, err
 	}
+	if !newEdits {
+		return newEditsResults(stored, temp), err
+	}
 	results := make([]driver.BulkResult, len(temp))
 	for i, r := range temp {
 		results[i] = driver.BulkResult(r)
@@ -65,6 +82,52 @@ func (d *db) BulkDocs(ctx context.Context, docs []interface{}, options map[strin
 	return results, err
 }
 
+// rawDocs returns docs, with any documents already encoded as JSON, as a
+// string or []byte, converted to json.RawMessage, so that they are sent as
+// they were read by validateRevisions for new_edits=false.
+func rawDocs(docs []interface{}) []interface{} {
+	var converted []interface{}
+	for i, doc := range docs {
+		switch doc.(type) {
+		case string, []byte:
+			if converted == nil {
+				converted = make([]interface{}, len(docs))
+				copy(converted, docs)
+			}
+			data, _ := rawJSON(doc)
+			converted[i] = json.RawMessage(data)
+		}
+	}
+	if converted == nil {
+		return docs
+	}
+	return converted
+}
+
+// BulkDocError represents an error for a single document returned by a
+// BulkDocs call. Its HTTP status distinguishes documents rejected by the
+// server, such as by a validate_doc_update function, from server failures.
+type BulkDocError struct {
+	ID     string `json:"id"`
+	Err    string `json:"error"`
+	Reason string `json:"reason"`
+}
+
+var _ error = &BulkDocError{}
+
+func (e *BulkDocError) Error() string {
+	if e.Reason == "" {
+		return e.Err
+	}
+	return e.Reason
+}
+
+// HTTPStatus returns the HTTP status code which corresponds to the error
+// reported by CouchDB for the document.
+func (e *BulkDocError) HTTPStatus() int {
+	return errorStatus(e.Err)
+}
+
 type bulkDocResult struct {
 	ID    string `json:"id"`
 	Rev   string `json:"rev"`
@@ -83,13 +146,12 @@ func (r *bulkDocResult) UnmarshalJSON(p []byte) error {
 	if err := json.Unmarshal(p, &target); err != nil {
 		return err
 	}
-	switch target.Error {
-	case "":
-		// No error
-	case "conflict":
-		r.Error = &kivik.Error{Status: http.StatusConflict, Err: errors.New(target.Reason)}
-	default:
-		r.Error = &kivik.Error{Status: http.StatusInternalServerError, Err: errors.New(target.Reason)}
+	if target.Error != "" {
+		r.Error = &BulkDocError{
+			ID:     r.ID,
+			Err:    target.Error,
+			Reason: target.Reason,
+		}
 	}
 	return nil
 }
@@ -16,12 +16,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"testing"
 
 	"gitlab.com/flimzy/testy"
+
+	"github.com/go-kivik/kivik/v4/driver"
 )
 
 func TestBulkDocs(t *testing.T) {
@@ -128,6 +131,26 @@ func TestBulkDocs(t *testing.T) {
 				}, nil
 			}),
 		},
+		{
+			name: "string documents",
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				defer req.Body.Close() // nolint: errcheck
+				var body struct {
+					Docs []interface{} `json:"docs"`
+				}
+				if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+					return nil, err
+				}
+				if d := testy.DiffInterface([]interface{}{`{"_id":"foo"}`, "eyJfaWQiOiJiYXIifQ=="}, body.Docs); d != nil {
+					return nil, fmt.Errorf("Unexpected docs:\n%s", d)
+				}
+				return &http.Response{
+					StatusCode: http.StatusCreated,
+					Body:       io.NopCloser(strings.NewReader("[]")),
+				}, nil
+			}),
+			docs: []interface{}{`{"_id":"foo"}`, []byte(`{"_id":"bar"}`)},
+		},
 		{
 			name:    "invalid full commit type",
 			db:      &db{},
@@ -144,6 +167,71 @@ func TestBulkDocs(t *testing.T) {
 	}
 }
 
+func TestBulkDocResultUnmarshalJSON(t *testing.T) {
+	type tst struct {
+		input    string
+		expected *bulkDocResult
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("success", tst{
+		input:    `{"ok":true,"id":"foo","rev":"1-xxx"}`,
+		expected: &bulkDocResult{ID: "foo", Rev: "1-xxx"},
+	})
+	tests.Add("conflict", tst{
+		input: `{"id":"foo","error":"conflict","reason":"Document update conflict."}`,
+		expected: &bulkDocResult{ID: "foo", Error: &BulkDocError{
+			ID: "foo", Err: "conflict", Reason: "Document update conflict.",
+		}},
+		status: http.StatusConflict,
+		err:    "Document update conflict.",
+	})
+	tests.Add("forbidden", tst{
+		input: `{"id":"foo","error":"forbidden","reason":"only admins may edit"}`,
+		expected: &bulkDocResult{ID: "foo", Error: &BulkDocError{
+			ID: "foo", Err: "forbidden", Reason: "only admins may edit",
+		}},
+		status: http.StatusForbidden,
+		err:    "only admins may edit",
+	})
+	tests.Add("unauthorized", tst{
+		input: `{"id":"foo","error":"unauthorized","reason":"You are not a db or server admin."}`,
+		expected: &bulkDocResult{ID: "foo", Error: &BulkDocError{
+			ID: "foo", Err: "unauthorized", Reason: "You are not a db or server admin.",
+		}},
+		status: http.StatusUnauthorized,
+		err:    "You are not a db or server admin.",
+	})
+	tests.Add("document too large", tst{
+		input: `{"id":"foo","error":"document_too_large","reason":"foo"}`,
+		expected: &bulkDocResult{ID: "foo", Error: &BulkDocError{
+			ID: "foo", Err: "document_too_large", Reason: "foo",
+		}},
+		status: http.StatusRequestEntityTooLarge,
+		err:    "foo",
+	})
+	tests.Add("unknown error, no reason", tst{
+		input: `{"id":"foo","error":"unknown_error"}`,
+		expected: &bulkDocResult{ID: "foo", Error: &BulkDocError{
+			ID: "foo", Err: "unknown_error",
+		}},
+		status: http.StatusInternalServerError,
+		err:    "unknown_error",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		result := new(bulkDocResult)
+		if err := json.Unmarshal([]byte(test.input), result); err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffInterface(test.expected, result); d != nil {
+			t.Error(d)
+		}
+		testy.StatusError(t, test.err, test.status, result.Error)
+	})
+}
+
 type closeTracker struct {
 	closed bool
 	io.ReadCloser
@@ -153,3 +241,128 @@ func (c *closeTracker) Close() error {
 	c.closed = true
 	return c.ReadCloser.Close()
 }
+
+func TestBulkDocsNewEditsFalse(t *testing.T) {
+	type tst struct {
+		db       *db
+		docs     []interface{}
+		options  map[string]interface{}
+		expected []driver.BulkResult
+		status   int
+		err      string
+	}
+	newEditsDB := func(response string) *db {
+		return newCustomDB(func(req *http.Request) (*http.Response, error) {
+			var body struct {
+				NewEdits *bool `json:"new_edits"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				return nil, err
+			}
+			if body.NewEdits == nil || *body.NewEdits {
+				return nil, errors.New("new_edits=false not in body")
+			}
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Body:       io.NopCloser(strings.NewReader(response)),
+			}, nil
+		})
+	}
+	tests := testy.NewTable()
+	tests.Add("invalid new_edits", tst{
+		db:      &db{},
+		options: map[string]interface{}{"new_edits": 0},
+		status:  http.StatusBadRequest,
+		err:     "kivik: option 'new_edits' must be bool, not 0",
+	})
+	tests.Add("missing _rev", tst{
+		db:      &db{},
+		docs:    []interface{}{map[string]interface{}{"_id": "foo"}},
+		options: map[string]interface{}{"new_edits": false},
+		status:  http.StatusBadRequest,
+		err:     "kivik: _rev required with new_edits=false",
+	})
+	tests.Add("inconsistent _revisions", tst{
+		db: &db{},
+		docs: []interface{}{map[string]interface{}{
+			"_id":        "foo",
+			"_rev":       "2-b",
+			"_revisions": Revisions{Start: 2, IDs: []string{"c", "a"}},
+		}},
+		options: map[string]interface{}{"new_edits": false},
+		status:  http.StatusBadRequest,
+		err:     "kivik: _rev 2-b does not match _revisions 2-c",
+	})
+	tests.Add("empty result", tst{
+		db: newEditsDB("[]"),
+		docs: []interface{}{
+			map[string]interface{}{"_id": "foo", "_rev": "2-b", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"b", "a"}}},
+			struct {
+				ID  string `json:"_id"`
+				Rev string `json:"_rev"`
+			}{ID: "bar", Rev: "1-a"},
+		},
+		options: map[string]interface{}{"new_edits": "false"},
+		expected: []driver.BulkResult{
+			{ID: "foo", Rev: "2-b"},
+			{ID: "bar", Rev: "1-a"},
+		},
+	})
+	tests.Add("rejected document", tst{
+		db: newEditsDB(`[{"id":"bar","error":"forbidden","reason":"not allowed"},{"id":"baz","error":"forbidden","reason":"not allowed"}]`),
+		docs: []interface{}{
+			map[string]interface{}{"_id": "foo", "_rev": "1-a"},
+			json.RawMessage(`{"_id":"bar","_rev":"1-b"}`),
+		},
+		options: map[string]interface{}{"new_edits": false},
+		expected: []driver.BulkResult{
+			{ID: "foo", Rev: "1-a"},
+			{ID: "bar", Rev: "1-b", Error: &BulkDocError{ID: "bar", Err: "forbidden", Reason: "not allowed"}},
+			{ID: "baz", Error: &BulkDocError{ID: "baz", Err: "forbidden", Reason: "not allowed"}},
+		},
+	})
+
+	tests.Add("two revisions of a document", tst{
+		db: newEditsDB(`[{"id":"foo","rev":"2-b","error":"forbidden","reason":"not allowed"}]`),
+		docs: []interface{}{
+			map[string]interface{}{"_id": "foo", "_rev": "2-a"},
+			map[string]interface{}{"_id": "foo", "_rev": "2-b"},
+		},
+		options: map[string]interface{}{"new_edits": false},
+		expected: []driver.BulkResult{
+			{ID: "foo", Rev: "2-a"},
+			{ID: "foo", Rev: "2-b", Error: &BulkDocError{ID: "foo", Err: "forbidden", Reason: "not allowed"}},
+		},
+	})
+	tests.Add("raw JSON documents", tst{
+		db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+			var body struct {
+				Docs []map[string]interface{} `json:"docs"`
+			}
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				return nil, err
+			}
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Body:       io.NopCloser(strings.NewReader("[]")),
+			}, nil
+		}),
+		docs: []interface{}{
+			`{"_id":"foo","_rev":"2-b","_revisions":{"start":2,"ids":["b","a"]}}`,
+			[]byte(`{"_id":"bar","_rev":"1-a"}`),
+		},
+		options: map[string]interface{}{"new_edits": false},
+		expected: []driver.BulkResult{
+			{ID: "foo", Rev: "2-b"},
+			{ID: "bar", Rev: "1-a"},
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		results, err := test.db.BulkDocs(context.Background(), test.docs, test.options)
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		if d := testy.DiffInterface(test.expected, results); d != nil {
+			t.Error(d)
+		}
+	})
+}
@@ -13,16 +13,28 @@
 package couchdb
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"strings"
+	"sync/atomic"
 
 	"github.com/go-kivik/couchdb/v4/chttp"
+	kivik "github.com/go-kivik/kivik/v4"
 	"github.com/go-kivik/kivik/v4/driver"
 )
 
 func (d *db) BulkGet(ctx context.Context, docs []driver.BulkGetReference, opts map[string]interface{}) (driver.Rows, error) {
+	multipartGet, err := boolOption(opts, OptionMultipartBulkGet)
+	if err != nil {
+		return nil, err
+	}
 	query, err := optionsToParams(opts)
 	if err != nil {
 		return nil, err
@@ -37,6 +49,9 @@ func (d *db) BulkGet(ctx context.Context, docs []driver.BulkGetReference, opts m
 			chttp.HeaderIdempotencyKey: []string{},
 		},
 	}
+	if multipartGet {
+		options.Accept = typeMPMixed + "," + typeJSON
+	}
 	resp, err := d.Client.DoReq(ctx, http.MethodPost, d.path("_bulk_get"), options)
 	if err != nil {
 		return nil, err
@@ -44,6 +59,16 @@ func (d *db) BulkGet(ctx context.Context, docs []driver.BulkGetReference, opts m
 	if err = chttp.ResponseError(resp); err != nil {
 		return nil, err
 	}
+	// Servers which don't support multipart/mixed responses for _bulk_get
+	// will simply reply with JSON, so the response type decides the parser.
+	if ct, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil && ct == typeMPMixed {
+		boundary := strings.Trim(params["boundary"], "\"")
+		if boundary == "" {
+			chttp.CloseBody(resp.Body)
+			return nil, &kivik.Error{Status: http.StatusBadGateway, Err: errors.New("kivik: boundary missing for multipart/mixed response")}
+		}
+		return newMultipartBulkGetRows(ctx, resp.Body, boundary), nil
+	}
 	return newBulkGetRows(ctx, resp.Body), nil
 }
 
@@ -62,6 +87,28 @@ func (e *BulkGetError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Err, e.Reason)
 }
 
+// HTTPStatus returns the HTTP status code which corresponds to the error
+// reported by CouchDB for the requested document revision.
+func (e *BulkGetError) HTTPStatus() int {
+	return errorStatus(e.Err)
+}
+
+// Missing returns true if the requested document or revision does not exist.
+func (e *BulkGetError) Missing() bool {
+	return e.Err == "not_found" && e.Reason != "deleted"
+}
+
+// Deleted returns true if the requested document exists, but has been
+// deleted.
+func (e *BulkGetError) Deleted() bool {
+	return e.Err == "not_found" && e.Reason == "deleted"
+}
+
+// Forbidden returns true if access to the requested document was denied.
+func (e *BulkGetError) Forbidden() bool {
+	return e.Err == "forbidden" || e.Err == "unauthorized"
+}
+
 type bulkResultDoc struct {
 	Doc   json.RawMessage `json:"ok,omitempty"`
 	Error *BulkGetError   `json:"error,omitempty"`
@@ -71,3 +118,151 @@ type bulkResult struct {
 	ID   string          `json:"id"`
 	Docs []bulkResultDoc `json:"docs"`
 }
+
+// multipartBulkGetRows iterates over a multipart/mixed _bulk_get response.
+// Each part holds a single document revision, as either a plain JSON document,
+// a JSON error, or a nested multipart/related document with its attachments.
+type multipartBulkGetRows struct {
+	body     io.ReadCloser
+	mpReader *multipart.Reader
+	closed   int32
+}
+
+var _ driver.Rows = &multipartBulkGetRows{}
+
+func newMultipartBulkGetRows(ctx context.Context, in io.ReadCloser, boundary string) driver.Rows {
+	body := newCancelableReadCloser(ctx, in)
+	return &multipartBulkGetRows{
+		body:     body,
+		mpReader: multipart.NewReader(body, boundary),
+	}
+}
+
+func (r *multipartBulkGetRows) Next(row *driver.Row) error {
+	if atomic.LoadInt32(&r.closed) == 1 {
+		return io.EOF
+	}
+	part, err := r.mpReader.NextPart()
+	if err == io.EOF {
+		if e := r.Close(); e != nil {
+			return e
+		}
+		return io.EOF
+	}
+	if err != nil {
+		return &kivik.Error{Status: http.StatusBadGateway, Err: err}
+	}
+	ct, params, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+	if err != nil {
+		return &kivik.Error{Status: http.StatusBadGateway, Err: err}
+	}
+	switch ct {
+	case typeJSON:
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return &kivik.Error{Status: http.StatusBadGateway, Err: err}
+		}
+		if params["error"] == "true" {
+			bulkErr := new(BulkGetError)
+			if err := json.Unmarshal(content, bulkErr); err != nil {
+				return &kivik.Error{Status: http.StatusBadGateway, Err: err}
+			}
+			*row = driver.Row{
+				ID:    bulkErr.ID,
+				Error: bulkErr,
+			}
+			return nil
+		}
+		var doc struct {
+			ID string `json:"_id"`
+		}
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return &kivik.Error{Status: http.StatusBadGateway, Err: err}
+		}
+		*row = driver.Row{
+			ID:  doc.ID,
+			Doc: bytes.NewReader(content),
+		}
+		return nil
+	case typeMPRelated:
+		boundary := strings.Trim(params["boundary"], "\"")
+		if boundary == "" {
+			return &kivik.Error{Status: http.StatusBadGateway, Err: errors.New("kivik: boundary missing for multipart/related part")}
+		}
+		content, err := inlineAttachments(multipart.NewReader(part, boundary))
+		if err != nil {
+			return &kivik.Error{Status: http.StatusBadGateway, Err: err}
+		}
+		*row = driver.Row{
+			ID:  part.Header.Get("X-Doc-Id"),
+			Doc: bytes.NewReader(content),
+		}
+		return nil
+	default:
+		return &kivik.Error{Status: http.StatusBadGateway, Err: fmt.Errorf("kivik: invalid content type in response part: %s", ct)}
+	}
+}
+
+func (r *multipartBulkGetRows) Close() error {
+	if atomic.AddInt32(&r.closed, 1) > 1 {
+		return nil
+	}
+	return r.body.Close()
+}
+
+// inlineAttachments reads a multipart/related document, and returns it as
+// JSON, with the content of each attachment which follows the document
+// inlined as base64 data, as it would be in a JSON response. This is what
+// makes the attachments available to rows.ScanDoc.
+func inlineAttachments(mpReader *multipart.Reader) ([]byte, error) {
+	part, err := mpReader.NextPart()
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.NewDecoder(part).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var atts map[string]map[string]json.RawMessage
+	if stubs, ok := doc[attachmentsKey]; ok {
+		if err := json.Unmarshal(stubs, &atts); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		part, err := mpReader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		_, params, err := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+		if err != nil {
+			return nil, fmt.Errorf("Content-Disposition: %s", err)
+		}
+		filename := params["filename"]
+		att, ok := atts[filename]
+		if !ok {
+			return nil, fmt.Errorf("File '%s' not in manifest", filename)
+		}
+		content, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		if att["data"], err = json.Marshal(content); err != nil {
+			return nil, err
+		}
+		delete(att, "follows")
+	}
+	if atts != nil {
+		if doc[attachmentsKey], err = json.Marshal(atts); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(doc)
+}
+
+func (r *multipartBulkGetRows) UpdateSeq() string { return "" }
+func (r *multipartBulkGetRows) Offset() int64     { return 0 }
+func (r *multipartBulkGetRows) TotalRows() int64  { return 0 }
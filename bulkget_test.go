@@ -19,12 +19,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 	"unicode"
 
 	"gitlab.com/flimzy/testy"
 
+	kivik "github.com/go-kivik/kivik/v4"
 	"github.com/go-kivik/kivik/v4/driver"
 	"github.com/google/go-cmp/cmp"
 )
@@ -147,6 +150,60 @@ func TestBulkGet(t *testing.T) {
 			Doc: strings.NewReader(`{"_id":"test1","_rev":"4-8158177eb5931358b3ddaadd6377cf00","moo":123,"oink":true,"_revisions":{"start":4,"ids":["8158177eb5931358b3ddaadd6377cf00","1c08032eef899e52f35cbd1cd5f93826","e22bea278e8c9e00f3197cb2edee8bf4","7d6ff0b102072755321aa0abb630865a"]},"_attachments":{"foo.txt":{"content_type":"text/plain","revpos":2,"digest":"md5-WiGw80mG3uQuqTKfUnIZsg==","length":9,"stub":true}}}`),
 		},
 	})
+	tests.Add("multipart accept header", tst{
+		db: &db{
+			client: newCustomClient(func(r *http.Request) (*http.Response, error) {
+				expected := "multipart/mixed,application/json"
+				if accept := r.Header.Get("Accept"); accept != expected {
+					return nil, fmt.Errorf("Unexpected Accept header: %s", accept)
+				}
+				if q := r.URL.RawQuery; q != "" {
+					return nil, fmt.Errorf("Unexpected query: %s", q)
+				}
+				return nil, errors.New("success")
+			}),
+			dbName: "xxx",
+		},
+		options: map[string]interface{}{
+			OptionMultipartBulkGet: true,
+		},
+		status: http.StatusBadGateway,
+		err:    "success",
+	})
+	tests.Add("invalid multipart option", tst{
+		db: &db{
+			client: newTestClient(nil, errors.New("should not be called")),
+		},
+		options: map[string]interface{}{
+			OptionMultipartBulkGet: "yes",
+		},
+		status: http.StatusBadRequest,
+		err:    "kivik: option 'kivik:multipart-bulk-get' must be bool, not string",
+	})
+	tests.Add("multipart response", tst{
+		db: &db{
+			client: newTestClient(&http.Response{
+				StatusCode: http.StatusOK,
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header: http.Header{
+					"Content-Type": []string{`multipart/mixed; boundary="outer"`},
+				},
+				Body: io.NopCloser(strings.NewReader("--outer\r\n" +
+					"Content-Type: application/json\r\n\r\n" +
+					`{"_id":"foo","_rev":"1-xxx"}` + "\r\n" +
+					"--outer--\r\n")),
+			}, nil),
+			dbName: "xxx",
+		},
+		options: map[string]interface{}{
+			OptionMultipartBulkGet: true,
+		},
+		expected: &driver.Row{
+			ID:  "foo",
+			Doc: strings.NewReader(`{"_id":"foo","_rev":"1-xxx"}`),
+		},
+	})
 	tests.Add("request", func(t *testing.T) interface{} {
 		return tst{
 			db: &db{
@@ -183,6 +240,64 @@ func TestBulkGet(t *testing.T) {
 	})
 }
 
+func TestBulkGetError(t *testing.T) {
+	type tst struct {
+		err       *BulkGetError
+		status    int
+		missing   bool
+		deleted   bool
+		forbidden bool
+	}
+	tests := testy.NewTable()
+	tests.Add("missing", tst{
+		err:     &BulkGetError{ID: "foo", Rev: "1-xxx", Err: "not_found", Reason: "missing"},
+		status:  http.StatusNotFound,
+		missing: true,
+	})
+	tests.Add("deleted", tst{
+		err:     &BulkGetError{ID: "foo", Err: "not_found", Reason: "deleted"},
+		status:  http.StatusNotFound,
+		deleted: true,
+	})
+	tests.Add("forbidden", tst{
+		err:       &BulkGetError{ID: "foo", Err: "forbidden", Reason: "You are not allowed to access this db."},
+		status:    http.StatusForbidden,
+		forbidden: true,
+	})
+	tests.Add("unauthorized", tst{
+		err:       &BulkGetError{ID: "foo", Err: "unauthorized", Reason: "You are not authorized to access this db."},
+		status:    http.StatusUnauthorized,
+		forbidden: true,
+	})
+	tests.Add("bad request", tst{
+		err:    &BulkGetError{ID: "foo", Rev: "xxx", Err: "bad_request", Reason: "Invalid rev format"},
+		status: http.StatusBadRequest,
+	})
+	tests.Add("illegal docid", tst{
+		err:    &BulkGetError{Err: "illegal_docid", Reason: "Document id must not be empty"},
+		status: http.StatusBadRequest,
+	})
+	tests.Add("unknown", tst{
+		err:    &BulkGetError{ID: "foo", Err: "unknown_error", Reason: "function_clause"},
+		status: http.StatusInternalServerError,
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		if status := kivik.HTTPStatus(test.err); status != test.status {
+			t.Errorf("Unexpected status: %d", status)
+		}
+		if missing := test.err.Missing(); missing != test.missing {
+			t.Errorf("Unexpected Missing(): %t", missing)
+		}
+		if deleted := test.err.Deleted(); deleted != test.deleted {
+			t.Errorf("Unexpected Deleted(): %t", deleted)
+		}
+		if forbidden := test.err.Forbidden(); forbidden != test.forbidden {
+			t.Errorf("Unexpected Forbidden(): %t", forbidden)
+		}
+	})
+}
+
 type row struct {
 	ID    string
 	Key   string
@@ -344,6 +459,167 @@ func TestGetBulkRowsIterator(t *testing.T) {
 	}
 }
 
+var bulkGetMultipartInput = strings.ReplaceAll(`--outer
+Content-Type: application/json
+
+{"_id":"foo","_rev":"1-4a7e4ae49c4366eaed8edeaea8f784ad","value":"this is foo"}
+--outer
+X-Doc-Id: bar
+X-Rev-Id: 2-9b71d36dfdd9b4815388eb91cc8fb61d
+Content-Type: multipart/related; boundary="inner"
+
+--inner
+Content-Type: application/json
+
+{"_id":"bar","_rev":"2-9b71d36dfdd9b4815388eb91cc8fb61d","_attachments":{"foo.txt":{"content_type":"text/plain","revpos":2,"digest":"md5-WiGw80mG3uQuqTKfUnIZsg==","length":9,"follows":true}}}
+--inner
+Content-Disposition: attachment; filename="foo.txt"
+Content-Type: text/plain
+Content-Length: 9
+
+test data
+--inner--
+--outer
+Content-Type: application/json; error="true"
+
+{"id":"baz","rev":"1-xxx","error":"not_found","reason":"missing"}
+--outer--
+`, "\n", "\r\n")
+
+func TestMultipartBulkGetRowsIterator(t *testing.T) {
+	type result struct {
+		ID  string
+		Doc string
+		Err string
+	}
+	expected := []result{
+		{
+			ID:  "foo",
+			Doc: `{"_id":"foo","_rev":"1-4a7e4ae49c4366eaed8edeaea8f784ad","value":"this is foo"}`,
+		},
+		{
+			ID:  "bar",
+			Doc: `{"_attachments":{"foo.txt":{"content_type":"text/plain","data":"dGVzdCBkYXRh","digest":"md5-WiGw80mG3uQuqTKfUnIZsg==","length":9,"revpos":2}},"_id":"bar","_rev":"2-9b71d36dfdd9b4815388eb91cc8fb61d"}`,
+		},
+		{ID: "baz", Err: "not_found: missing"},
+	}
+	results := []result{}
+	rows := newMultipartBulkGetRows(context.TODO(), io.NopCloser(strings.NewReader(bulkGetMultipartInput)), "outer")
+	for {
+		row := &driver.Row{}
+		err := rows.Next(row)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() failed: %s", err)
+		}
+		res := result{ID: row.ID}
+		if row.Error != nil {
+			res.Err = row.Error.Error()
+		}
+		if row.Doc != nil {
+			doc, err := io.ReadAll(row.Doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res.Doc = string(doc)
+		}
+		results = append(results, res)
+		if len(results) > 10 {
+			t.Fatalf("Ran too many iterations.")
+		}
+	}
+	if d := testy.DiffInterface(expected, results); d != nil {
+		t.Error(d)
+	}
+	if err := rows.Next(&driver.Row{}); err != io.EOF {
+		t.Errorf("Calling Next() after end returned unexpected error: %s", err)
+	}
+	if err := rows.Close(); err != nil {
+		t.Errorf("Error closing rows iterator: %s", err)
+	}
+}
+
+func TestMultipartBulkGetRowsUnreadDocs(t *testing.T) {
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		rows := newMultipartBulkGetRows(context.TODO(), io.NopCloser(strings.NewReader(bulkGetMultipartInput)), "outer")
+		for {
+			row := &driver.Row{}
+			if err := rows.Next(row); err != nil {
+				if err != io.EOF {
+					t.Fatal(err)
+				}
+				break
+			}
+			// Close half of the iterators while positioned on the row
+			// with attachments, and drain the others.
+			if i%2 == 0 && row.ID == "bar" {
+				break
+			}
+		}
+		if err := rows.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("%d goroutines leaked", after-before)
+	}
+}
+
+func TestBulkGetMultipartAttachments(t *testing.T) {
+	db := newTestKivikDB(t, func(*http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header: http.Header{
+				"Content-Type": []string{`multipart/mixed; boundary="outer"`},
+			},
+			Body: io.NopCloser(strings.NewReader(bulkGetMultipartInput)),
+		}, nil
+	})
+	rows := db.BulkGet(context.Background(), []kivik.BulkGetReference{{ID: "foo"}, {ID: "bar"}}, kivik.Options{
+		OptionMultipartBulkGet: true,
+	})
+	defer rows.Close() // nolint: errcheck
+	type doc struct {
+		ID          string            `json:"_id"`
+		Attachments kivik.Attachments `json:"_attachments"`
+	}
+	contents := map[string]map[string]string{}
+	for rows.Next() {
+		var d doc
+		if err := rows.ScanDoc(&d); err != nil {
+			if kivik.HTTPStatus(err) == http.StatusNotFound {
+				continue
+			}
+			t.Fatal(err)
+		}
+		contents[d.ID] = map[string]string{}
+		for filename, att := range d.Attachments {
+			content, err := io.ReadAll(att.Content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contents[d.ID][filename] = att.ContentType + ": " + string(content)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]map[string]string{
+		"foo": {},
+		"bar": {"foo.txt": "text/plain: test data"},
+	}
+	if d := testy.DiffInterface(expected, contents); d != nil {
+		t.Error(d)
+	}
+}
+
 func removeSpaces(in string) string {
 	return strings.Map(func(r rune) rune {
 		if unicode.IsSpace(r) {
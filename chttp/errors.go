@@ -26,6 +26,9 @@ type HTTPError struct {
 	// metadata will typically be in tact for debugging purposes.
 	Response *http.Response `json:"-"`
 
+	// Err is the server-supplied error code, such as "not_found".
+	Err string `json:"error"`
+
 	// Reason is the server-supplied error reason.
 	Reason string `json:"reason"`
 }
@@ -117,6 +117,7 @@ func TestResponseError(t *testing.T) {
 					Response: &http.Response{
 						StatusCode: http.StatusBadRequest,
 					},
+					Err:    "illegal_database_name",
 					Reason: "Name: '_foo'. Only lowercase characters (a-z), digits (0-9), and any of the characters _, $, (, ), +, -, and / are allowed. Must begin with a letter.",
 				},
 			},
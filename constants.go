@@ -60,6 +60,184 @@ const (
 	// attachments.
 	OptionNoMultipartGet = internal.OptionNoMultipartGet
 
+	// OptionMultipartBulkGet instructs
+	// [github.com/go-kivik/kivik/v4.DB.BulkGet] to request a multipart/mixed
+	// response, so that attachments are sent as binary MIME parts, rather than
+	// as base64 by the server. The attachments of each document are read with
+	// the document, and inlined in its _attachments object as data, as in a
+	// JSON response, so that they can be read with ScanDoc into
+	// [github.com/go-kivik/kivik/v4.Attachments].
+	//
+	// Example:
+	//
+	//    rows := db.BulkGet(ctx, docs, kivik.Options{
+	//        "attachments":                 true,
+	//        couchdb.OptionMultipartBulkGet: true,
+	//    })
+	OptionMultipartBulkGet = internal.OptionMultipartBulkGet
+
+	// OptionRangeOffset instructs
+	// [github.com/go-kivik/kivik/v4.DB.GetAttachment] to fetch only the part
+	// of the attachment starting at the given byte offset, using an HTTP Range
+	// request. The value must be an int or int64.
+	//
+	// Example:
+	//
+	//    att, err := db.GetAttachment(ctx, "doc_id", "video.mp4", kivik.Options{couchdb.OptionRangeOffset: int64(1024)})
+	OptionRangeOffset = internal.OptionRangeOffset
+
+	// OptionRangeLength limits the number of bytes fetched by
+	// [github.com/go-kivik/kivik/v4.DB.GetAttachment], using an HTTP Range
+	// request. It may be combined with OptionRangeOffset. The value must be
+	// an int or int64.
+	OptionRangeLength = internal.OptionRangeLength
+
+	// OptionVerifyDigest enables MD5 verification of attachment content. When
+	// passed to [github.com/go-kivik/kivik/v4.DB.GetAttachment], the content
+	// is hashed as it is read, and the final Read returns an error if the sum
+	// does not match the attachment digest. When passed to
+	// [github.com/go-kivik/kivik/v4.DB.PutAttachment], the digest is sent in
+	// the Content-MD5 header, so that CouchDB rejects corrupted uploads. The
+	// value must be a bool.
+	//
+	// Example:
+	//
+	//    att, err := db.GetAttachment(ctx, "doc_id", "foo.txt", kivik.Options{couchdb.OptionVerifyDigest: true})
+	OptionVerifyDigest = internal.OptionVerifyDigest
+
+	// OptionRawAttachmentEncoding requests attachments in the encoding in
+	// which CouchDB stores them, such as gzip for compressible content types,
+	// and returns the content undecoded, with the ContentEncoding and
+	// EncodedLength fields of the attachment set. It applies to
+	// [github.com/go-kivik/kivik/v4.DB.GetAttachment], and to attachments
+	// fetched with [github.com/go-kivik/kivik/v4.DB.Get]. The value must be a
+	// bool.
+	OptionRawAttachmentEncoding = internal.OptionRawAttachmentEncoding
+
+	// OptionCompressedAttachments requests attachments in the encoding in
+	// which CouchDB stores them, to save bandwidth, and decodes gzip content
+	// transparently as it is read. It may not be combined with
+	// OptionRawAttachmentEncoding. The value must be a bool.
+	//
+	// To upload pre-compressed content, set the ContentEncoding field of the
+	// attachment passed to [github.com/go-kivik/kivik/v4.DB.PutAttachment]
+	// instead.
+	OptionCompressedAttachments = internal.OptionCompressedAttachments
+
+	// OptionFindPageSize instructs [github.com/go-kivik/kivik/v4.DB.Find] to
+	// fetch the results in pages of the given size, re-issuing the query with
+	// the bookmark of the previous page until an empty page is returned. A
+	// limit in the query, if set, applies to the complete result set, as with
+	// OptionPageSize. Cancelling the context stops further requests. The value
+	// must be an int or int64.
+	//
+	// Example:
+	//
+	//    rows := db.Find(ctx, query, kivik.Options{couchdb.OptionFindPageSize: 100})
+	OptionFindPageSize = internal.OptionFindPageSize
+
+	// OptionPageSize instructs [github.com/go-kivik/kivik/v4.DB.Query] and
+	// [github.com/go-kivik/kivik/v4.DB.AllDocs] to fetch the results in pages
+	// of the given size. Rather than skip, which grows more expensive with
+	// each page, every page after the first starts at the key and document
+	// ID of the row following the previous page, as recommended by the
+	// CouchDB documentation. A limit, if set, applies to the complete result
+	// set. When the keys option is used, the keys are instead requested in
+	// batches of the page size. The value must be an int or int64, as must
+	// the limit option, if set.
+	//
+	// Example:
+	//
+	//    rows := db.Query(ctx, "ddoc", "view", kivik.Options{couchdb.OptionPageSize: 1000})
+	OptionPageSize = internal.OptionPageSize
+
+	// OptionExecutionStats requests execution statistics from
+	// [github.com/go-kivik/kivik/v4.DB.Find]. The value must be a non-nil
+	// *ExecutionStats, which is populated once the result set has been
+	// iterated completely. Combined with OptionFindPageSize, the stats of all
+	// pages are summed.
+	//
+	// Example:
+	//
+	//    stats := new(couchdb.ExecutionStats)
+	//    rows := db.Find(ctx, query, kivik.Options{couchdb.OptionExecutionStats: stats})
+	//    for rows.Next() { /* ... */ }
+	//    fmt.Println(stats.TotalDocsExamined)
+	OptionExecutionStats = internal.OptionExecutionStats
+
+	// OptionIndexProgress instructs [github.com/go-kivik/kivik/v4.DB.Query]
+	// to trigger a build of the view index, and to wait for the build to
+	// complete before querying the view, by polling the design document's
+	// _info. The value must be a func(IndexProgress), which is called with
+	// the progress of the build, read from the server's active tasks, after
+	// each poll, and once more when the build is complete. See also WarmView.
+	//
+	// Example:
+	//
+	//    rows := db.Query(ctx, "ddoc", "view", kivik.Options{
+	//        couchdb.OptionIndexProgress: func(p couchdb.IndexProgress) {
+	//            fmt.Printf("%d%% complete\n", p.Progress)
+	//        },
+	//    })
+	OptionIndexProgress = internal.OptionIndexProgress
+
+	// OptionTransientReplication instructs
+	// [github.com/go-kivik/kivik/v4.Client.Replicate] to start a transient
+	// replication with the /_replicate endpoint, rather than storing a
+	// document in the _replicator database. Other options, such as
+	// create_target, doc_ids, selector, filter, continuous and cancel, are
+	// passed to the server. A continuous replication returns once it has
+	// started, and is cancelled by Delete. Otherwise, Replicate blocks until
+	// the replication is complete. The value must be true, or a non-nil
+	// *TransientReplicationResult, which receives the server's response.
+	//
+	// Example:
+	//
+	//    result := new(couchdb.TransientReplicationResult)
+	//    _, err := client.Replicate(ctx, target, source, kivik.Options{
+	//        couchdb.OptionTransientReplication: result,
+	//        "create_target":                    true,
+	//    })
+	OptionTransientReplication = internal.OptionTransientReplication
+
+	// OptionReplicatorDB sets the replicator database used by
+	// [github.com/go-kivik/kivik/v4.Client.Replicate] and
+	// [github.com/go-kivik/kivik/v4.Client.GetReplications], in place of the
+	// default _replicator. CouchDB 2 and later support any database whose
+	// name ends in /_replicator. Replications returned by either method are
+	// then updated and deleted in that database. When the option is not set,
+	// GetReplications lists the replications of all replicator databases, if
+	// supported by the server. The value must be a string.
+	//
+	// Example:
+	//
+	//    rep, err := client.Replicate(ctx, target, source, kivik.Options{
+	//        couchdb.OptionReplicatorDB: "tenantA/_replicator",
+	//    })
+	OptionReplicatorDB = internal.OptionReplicatorDB
+
+	// OptionSchedulerJobs instructs
+	// [github.com/go-kivik/kivik/v4.Client.GetReplications] to read the jobs
+	// of the replication scheduler, rather than to list replications, which
+	// are then not returned. The value must be a non-nil *SchedulerJobs,
+	// which receives a page of jobs, selected with the limit and skip options,
+	// or a non-nil *SchedulerJob, whose ID is set to the replication ID of
+	// the job to read. Requires CouchDB 2.1 or later.
+	//
+	// Example:
+	//
+	//    jobs := new(couchdb.SchedulerJobs)
+	//    _, err := client.GetReplications(ctx, kivik.Options{
+	//        couchdb.OptionSchedulerJobs: jobs,
+	//        "limit":                     10,
+	//    })
+	//
+	//    job := &couchdb.SchedulerJob{ID: "a0f2e3c4+continuous"}
+	//    _, err = client.GetReplications(ctx, kivik.Options{
+	//        couchdb.OptionSchedulerJobs: job,
+	//    })
+	OptionSchedulerJobs = internal.OptionSchedulerJobs
+
 	// OptionNoCompressedRequests disables gzip content encoding for request
 	// bodies. Only valid as an option to [github.com/go-kivik/kivik/v4.New].
 	OptionNoCompressedRequests = internal.OptionNoCompressedRequests
@@ -68,4 +246,5 @@ const (
 const (
 	typeJSON      = "application/json"
 	typeMPRelated = "multipart/related"
+	typeMPMixed   = "multipart/mixed"
 )
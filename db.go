@@ -89,6 +89,68 @@ func optionsToParams(opts ...map[string]interface{}) (url.Values, error) {
 
 // rowsQuery performs a query that returns a rows iterator.
 func (d *db) rowsQuery(ctx context.Context, path string, opts map[string]interface{}) (driver.Rows, error) {
+	pageSize, paged, err := int64Option(opts, OptionPageSize)
+	if err != nil {
+		return nil, err
+	}
+	if paged {
+		return d.pagedRowsQuery(ctx, path, pageSize, opts)
+	}
+	return d.singleRowsQuery(ctx, path, opts)
+}
+
+// pagedRowsQuery performs a query that returns a rows iterator, which fetches
+// the results in pages of pageSize rows, using key ranges rather than skip.
+func (d *db) pagedRowsQuery(ctx context.Context, path string, pageSize int64, opts map[string]interface{}) (driver.Rows, error) {
+	if pageSize <= 0 {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: page size must be positive")}
+	}
+	if _, ok := opts["queries"]; ok {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: paging is not supported for multiple queries")}
+	}
+	limit, hasLimit, err := int64Option(opts, "limit")
+	if err != nil {
+		return nil, err
+	}
+	if !hasLimit {
+		limit = -1
+	}
+	r := &pagedRows{
+		ctx: ctx,
+		query: func(ctx context.Context, opts map[string]interface{}) (driver.Rows, error) {
+			return d.singleRowsQuery(ctx, path, opts)
+		},
+		opts:     opts,
+		pageSize: pageSize,
+		limit:    limit,
+	}
+	if keys, ok := opts["keys"]; ok {
+		delete(opts, "keys")
+		if r.keys, err = splitKeys(keys); err != nil {
+			return nil, err
+		}
+		r.keysMode = true
+	}
+	if err := r.nextPage(nil); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// splitKeys converts the keys option to a list of JSON-encoded keys.
+func splitKeys(keys interface{}) ([]json.RawMessage, error) {
+	raw, err := encodeKey(keys)
+	if err != nil {
+		return nil, err
+	}
+	var result []json.RawMessage
+	if err := json.Unmarshal([]byte(raw), &result); err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: keys must be an array: %w", err)}
+	}
+	return result, nil
+}
+
+func (d *db) singleRowsQuery(ctx context.Context, path string, opts map[string]interface{}) (driver.Rows, error) {
 	payload := make(map[string]interface{})
 	if keys := opts["keys"]; keys != nil {
 		delete(opts, "keys")
@@ -154,11 +216,30 @@ func (d *db) Query(ctx context.Context, ddoc, view string, opts map[string]inter
 		delete(opts, OptionPartition)
 		reqPath = path.Join("_partition", part, reqPath)
 	}
+	progress, err := indexProgressOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	if progress != nil {
+		if err := d.waitForIndex(ctx, reqPath, ddoc, progress); err != nil {
+			return nil, err
+		}
+	}
 	return d.rowsQuery(ctx, reqPath, opts)
 }
 
 // Get fetches the requested document.
 func (d *db) Get(ctx context.Context, docID string, options map[string]interface{}) (*driver.Document, error) {
+	if docID == purgedInfosID || docID == purgedInfosLimitID {
+		return d.getPurgedInfos(ctx, docID)
+	}
+	enc, err := attachmentEncoding(options)
+	if err != nil {
+		return nil, err
+	}
+	if enc != attEncodingDefault {
+		options["att_encoding_info"] = true
+	}
 	resp, err := d.get(ctx, http.MethodGet, docID, options)
 	if err != nil {
 		return nil, err
@@ -190,53 +271,186 @@ func (d *db) Get(ctx context.Context, docID string, options map[string]interface
 		if boundary == "" {
 			return nil, &kivik.Error{Status: http.StatusBadGateway, Err: errors.New("kivik: boundary missing for multipart/related response")}
 		}
-		mpReader := multipart.NewReader(resp.Body, boundary)
-		body, err := mpReader.NextPart()
+		body, atts, err := readMultipartDoc(resp.Body, multipart.NewReader(resp.Body, boundary))
 		if err != nil {
-			return nil, &kivik.Error{Status: http.StatusBadGateway, Err: err}
+			return nil, err
 		}
+		atts.enc = enc
+
+		return &driver.Document{
+			Rev:         rev,
+			Body:        body,
+			Attachments: atts,
+		}, nil
+	default:
+		return nil, &kivik.Error{Status: http.StatusBadGateway, Err: fmt.Errorf("kivik: invalid content type in response: %s", ct)}
+	}
+}
+
+// readMultipartDoc reads the leading JSON part of a multipart/related stream,
+// and returns the document body along with an iterator over the attachments
+// which follow it. content is closed when the attachments iterator is closed.
+//
+// The document body is streamed, not buffered, so the attachment manifest
+// is parsed from the body as it is read. Any unread portion of the body is
+// consumed when the body is closed, or when the first attachment is read.
+func readMultipartDoc(content io.ReadCloser, mpReader *multipart.Reader) (*attStubsReader, *multipartAttachments, error) {
+	body, err := mpReader.NextPart()
+	if err != nil {
+		return nil, nil, &kivik.Error{Status: http.StatusBadGateway, Err: err}
+	}
+	stubs := newAttStubsReader(body)
+	return stubs, &multipartAttachments{
+		content:  content,
+		mpReader: mpReader,
+		stubs:    stubs,
+	}, nil
+}
+
+// attStubsReader passes through a JSON document, while extracting the
+// top-level _attachments object from the stream.
+type attStubsReader struct {
+	r    io.Reader
+	pw   *io.PipeWriter
+	done chan struct{}
+
+	// meta and err are only valid once done is closed.
+	meta map[string]attMeta
+	err  error
+}
+
+var _ io.ReadCloser = &attStubsReader{}
+
+func newAttStubsReader(in io.Reader) *attStubsReader {
+	pr, pw := io.Pipe()
+	r := &attStubsReader{
+		r:    io.TeeReader(in, pw),
+		pw:   pw,
+		done: make(chan struct{}),
+	}
+	go r.parse(pr)
+	return r
+}
+
+func (r *attStubsReader) parse(pr *io.PipeReader) {
+	defer close(r.done)
+	r.meta, r.err = parseAttStubs(json.NewDecoder(pr))
+	// Keep consuming, so that reads of the document never block.
+	_, _ = io.Copy(io.Discard, pr)
+}
+
+func (r *attStubsReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	switch err {
+	case nil:
+	case io.EOF:
+		_ = r.pw.Close()
+	default:
+		_ = r.pw.CloseWithError(err)
+	}
+	return n, err
+}
+
+// Close consumes the remainder of the document, so that the attachment
+// manifest is complete.
+func (r *attStubsReader) Close() error {
+	_, err := r.finish()
+	return err
+}
 
-		// TODO: Use a TeeReader here, to avoid slurping the entire body into memory at once
-		content, err := io.ReadAll(body)
+// finish consumes the remainder of the document, and returns the parsed
+// attachment manifest.
+func (r *attStubsReader) finish() (map[string]attMeta, error) {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		<-r.done
+		return nil, err
+	}
+	<-r.done
+	return r.meta, r.err
+}
+
+// abort stops the parser, if it is still running.
+func (r *attStubsReader) abort() {
+	_ = r.pw.CloseWithError(io.ErrClosedPipe)
+}
+
+// parseAttStubs reads a JSON object from dec, and returns the value of the
+// _attachments key, if any. Other values are skipped token by token, so that
+// large documents are never held in memory.
+func parseAttStubs(dec *json.Decoder) (map[string]attMeta, error) {
+	if err := consumeDelim(dec, json.Delim('{')); err != nil {
+		return nil, err
+	}
+	for dec.More() {
+		key, err := nextKey(dec)
 		if err != nil {
-			return nil, &kivik.Error{Status: http.StatusBadGateway, Err: err}
+			return nil, err
 		}
-		var metaDoc struct {
-			Attachments map[string]attMeta `json:"_attachments"`
+		if key == attachmentsKey {
+			var meta map[string]attMeta
+			err := dec.Decode(&meta)
+			return meta, err
 		}
-		if err := json.Unmarshal(content, &metaDoc); err != nil {
-			return nil, &kivik.Error{Status: http.StatusBadGateway, Err: err}
+		if err := skipValue(dec); err != nil {
+			return nil, err
 		}
+	}
+	return nil, nil
+}
 
-		return &driver.Document{
-			Rev:  rev,
-			Body: io.NopCloser(bytes.NewBuffer(content)),
-			Attachments: &multipartAttachments{
-				content:  resp.Body,
-				mpReader: mpReader,
-				meta:     metaDoc.Attachments,
-			},
-		}, nil
-	default:
-		return nil, &kivik.Error{Status: http.StatusBadGateway, Err: fmt.Errorf("kivik: invalid content type in response: %s", ct)}
+// skipValue consumes the next JSON value from dec.
+func skipValue(dec *json.Decoder) error {
+	var depth int
+	for {
+		t, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
 	}
 }
 
 type attMeta struct {
-	ContentType string `json:"content_type"`
-	Size        *int64 `json:"length"`
-	Follows     bool   `json:"follows"`
+	ContentType   string `json:"content_type"`
+	Size          *int64 `json:"length"`
+	Follows       bool   `json:"follows"`
+	Encoding      string `json:"encoding"`
+	EncodedLength int64  `json:"encoded_length"`
 }
 
 type multipartAttachments struct {
 	content  io.ReadCloser
 	mpReader *multipart.Reader
 	meta     map[string]attMeta
+
+	// enc is the attachment encoding option of the request. gzip-encoded
+	// attachments are only decoded with attEncodingDecode.
+	enc attEncoding
+
+	// stubs, if set, is the document body from which meta is read, before
+	// the first attachment.
+	stubs *attStubsReader
 }
 
 var _ driver.Attachments = &multipartAttachments{}
 
 func (a *multipartAttachments) Next(att *driver.Attachment) error {
+	if a.stubs != nil {
+		meta, err := a.stubs.finish()
+		if err != nil {
+			return &kivik.Error{Status: http.StatusBadGateway, Err: err}
+		}
+		a.meta = meta
+		a.stubs = nil
+	}
 	part, err := a.mpReader.NextPart()
 	switch err {
 	case io.EOF:
@@ -285,10 +499,28 @@ func (a *multipartAttachments) Next(att *driver.Attachment) error {
 		Content:         part,
 		ContentEncoding: part.Header.Get("Content-Encoding"),
 	}
+	if att.ContentEncoding == "" {
+		att.ContentEncoding = meta.Encoding
+	}
+	if att.ContentEncoding != "" {
+		att.EncodedLength = meta.EncodedLength
+	}
+	if att.ContentEncoding == "gzip" && a.enc == attEncodingDecode {
+		content, err := newGzipReadCloser(part)
+		if err != nil {
+			return &kivik.Error{Status: http.StatusBadGateway, Err: err}
+		}
+		att.Content = content
+		att.ContentEncoding = ""
+		att.EncodedLength = 0
+	}
 	return nil
 }
 
 func (a *multipartAttachments) Close() error {
+	if a.stubs != nil {
+		a.stubs.abort()
+	}
 	return a.content.Close()
 }
 
@@ -370,6 +602,12 @@ func putOpts(doc interface{}, options map[string]interface{}) (*chttp.Options, e
 	}
 	if _, ok := options[OptionNoMultipartPut]; !ok {
 		if atts, ok := extractAttachments(doc); ok {
+			if !hasFollows(atts) {
+				// Only stubs of unchanged attachments; there is no content
+				// to upload.
+				opts.Body = replaceAttachments(chttp.EncodeBody(doc), atts)
+				return opts, nil
+			}
 			boundary, size, multipartBody, err := newMultipartAttachments(chttp.EncodeBody(doc), atts)
 			if err != nil {
 				return nil, err
@@ -388,6 +626,15 @@ func (d *db) Put(ctx context.Context, docID string, doc interface{}, options map
 	if docID == "" {
 		return "", missingArg("docID")
 	}
+	newEdits, err := newEditsOption(options)
+	if err != nil {
+		return "", err
+	}
+	if !newEdits {
+		if _, _, err := validateRevisions(doc); err != nil {
+			return "", err
+		}
+	}
 	opts, err := putOpts(doc, options)
 	if err != nil {
 		return "", err
@@ -496,7 +743,12 @@ func createMultipart(w *multipart.Writer, r io.ReadCloser, atts *kivik.Attachmen
 	// Sort the filenames to ensure order consistent with json.Marshal's ordering
 	// of the stubs in the body
 	filenames := make([]string, 0, len(*atts))
-	for filename := range *atts {
+	for filename, att := range *atts {
+		if att.Stub {
+			// Unchanged attachments are kept by the server, and have no
+			// part of their own.
+			continue
+		}
 		filenames = append(filenames, filename)
 	}
 	sort.Strings(filenames)
@@ -630,12 +882,45 @@ func replaceAttachments(in io.ReadCloser, atts *kivik.Attachments) io.ReadCloser
 	return r
 }
 
+// hasFollows returns true if any of atts has content to be uploaded, rather
+// than being a stub of an unchanged attachment.
+func hasFollows(atts *kivik.Attachments) bool {
+	for _, att := range *atts {
+		if !att.Stub {
+			return true
+		}
+	}
+	return false
+}
+
 type stub struct {
 	ContentType string `json:"content_type"`
 	Size        int64  `json:"length"`
+
+	// unchanged marks a stub of an existing attachment, which is kept by the
+	// server as-is.
+	unchanged bool
+	revPos    int64
+	digest    string
 }
 
 func (s *stub) MarshalJSON() ([]byte, error) {
+	if s.unchanged {
+		type stubJSON struct {
+			ContentType string `json:"content_type,omitempty"`
+			Size        int64  `json:"length,omitempty"`
+			RevPos      int64  `json:"revpos,omitempty"`
+			Digest      string `json:"digest,omitempty"`
+			Stub        bool   `json:"stub"`
+		}
+		return json.Marshal(stubJSON{
+			ContentType: s.ContentType,
+			Size:        s.Size,
+			RevPos:      s.revPos,
+			Digest:      s.digest,
+			Stub:        true,
+		})
+	}
 	type attJSON struct {
 		stub
 		Follows bool `json:"follows"`
@@ -653,6 +938,16 @@ func attachmentStubs(atts *kivik.Attachments) (map[string]*stub, error) {
 	}
 	result := make(map[string]*stub, len(*atts))
 	for filename, att := range *atts {
+		if att.Stub {
+			result[filename] = &stub{
+				ContentType: att.ContentType,
+				Size:        att.Size,
+				unchanged:   true,
+				revPos:      att.RevPos,
+				digest:      att.Digest,
+			}
+			continue
+		}
 		if err := attachmentSize(att); err != nil {
 			return nil, err
 		}
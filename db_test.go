@@ -24,6 +24,7 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -318,6 +319,30 @@ Content-Length: 86
 			},
 		},
 	})
+	tests.Add("compressed attachments", tt{
+		db: newCustomDB(func(r *http.Request) (*http.Response, error) {
+			if info := r.URL.Query().Get("att_encoding_info"); info != "true" {
+				return nil, fmt.Errorf("Unexpected att_encoding_info: %s", info)
+			}
+			if _, ok := r.URL.Query()[OptionCompressedAttachments]; ok {
+				return nil, errors.New("option passed as query parameter")
+			}
+			return nil, errors.New("not an error")
+		}),
+		options: map[string]interface{}{OptionCompressedAttachments: true},
+		id:      "foo",
+		status:  http.StatusBadGateway,
+		err:     "not an error",
+	})
+	tests.Add("conflicting encoding options", tt{
+		options: map[string]interface{}{
+			OptionCompressedAttachments: true,
+			OptionRawAttachmentEncoding: true,
+		},
+		id:     "foo",
+		status: http.StatusBadRequest,
+		err:    "kivik: options 'kivik:raw-attachment-encoding' and 'kivik:compressed-attachments' are mutually exclusive",
+	})
 	tests.Add("multipart attachments, doc content length", tt{
 		// response borrowed from http://docs.couchdb.org/en/2.1.1/api/document/common.html#efficient-multiple-attachments-retrieving
 		db: newTestDB(&http.Response{
@@ -861,6 +886,78 @@ func TestPut(t *testing.T) {
 			status: http.StatusBadGateway,
 			err:    `Put "?http://127.0.0.1:1/animals/cow"?: dial tcp ([::1]|127.0.0.1):1: (getsockopt|connect): connection refused`,
 		},
+		{
+			name: "unchanged attachment stubs only",
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				if ct := req.Header.Get("Content-Type"); ct != typeJSON {
+					return nil, fmt.Errorf("Unexpected Content-Type: %s", ct)
+				}
+				body, err := io.ReadAll(req.Body)
+				if err != nil {
+					return nil, err
+				}
+				expected := `{"_attachments":{"foo.txt":{"content_type":"text/plain","revpos":1,"digest":"md5-xxx","stub":true}},"feet":4}`
+				if d := testy.DiffJSON([]byte(expected), body); d != nil {
+					return nil, fmt.Errorf("Unexpected body:\n%s", d)
+				}
+				return nil, errors.New("success")
+			}),
+			id: "foo",
+			doc: map[string]interface{}{
+				"feet": 4,
+				"_attachments": &kivik.Attachments{
+					"foo.txt": &kivik.Attachment{Filename: "foo.txt", ContentType: "text/plain", Stub: true, RevPos: 1, Digest: "md5-xxx"},
+				},
+			},
+			status: http.StatusBadGateway,
+			err:    "success",
+		},
+		{
+			name: "unchanged stub with new attachment",
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				ct, params, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+				if err != nil {
+					return nil, err
+				}
+				if ct != typeMPRelated {
+					return nil, fmt.Errorf("Unexpected Content-Type: %s", ct)
+				}
+				mpReader := multipart.NewReader(req.Body, params["boundary"])
+				var parts []string
+				for {
+					part, err := mpReader.NextPart()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						return nil, err
+					}
+					content, err := io.ReadAll(part)
+					if err != nil {
+						return nil, err
+					}
+					parts = append(parts, string(content))
+				}
+				expected := []string{
+					`{"_attachments":{"bar.txt":{"content_type":"text/plain","length":12,"follows":true},"foo.txt":{"content_type":"text/plain","stub":true}},"feet":4}` + "\n",
+					"new content\n",
+				}
+				if d := testy.DiffInterface(expected, parts); d != nil {
+					return nil, fmt.Errorf("Unexpected parts:\n%s", d)
+				}
+				return nil, errors.New("success")
+			}),
+			id: "foo",
+			doc: map[string]interface{}{
+				"feet": 4,
+				"_attachments": &kivik.Attachments{
+					"foo.txt": &kivik.Attachment{Filename: "foo.txt", ContentType: "text/plain", Stub: true},
+					"bar.txt": &kivik.Attachment{Filename: "bar.txt", ContentType: "text/plain", Content: Body("new content")},
+				},
+			},
+			status: http.StatusBadGateway,
+			err:    "success",
+		},
 		func() pTest {
 			db := realDB(t)
 			return pTest{
@@ -1474,6 +1571,214 @@ func TestRowsQuery(t *testing.T) {
 	}
 }
 
+func TestPagedRowsQuery(t *testing.T) {
+	type viewRow struct {
+		ID  string `json:"id"`
+		Key int    `json:"key"`
+	}
+	// viewRows are sorted as CouchDB sorts view rows, by key, then by ID.
+	viewRows := []viewRow{
+		{ID: "a", Key: 1},
+		{ID: "b", Key: 2},
+		{ID: "c", Key: 2},
+		{ID: "d", Key: 2},
+		{ID: "e", Key: 3},
+		{ID: "f", Key: 4},
+	}
+	// fakeView serves viewRows, honoring the subset of view parameters used
+	// for paging.
+	fakeView := func(t *testing.T, queries *[]url.Values) *db {
+		t.Helper()
+		return newCustomDB(func(req *http.Request) (*http.Response, error) {
+			q := req.URL.Query()
+			*queries = append(*queries, q)
+			descending := q.Get("descending") == "true"
+			rows := make([]viewRow, len(viewRows))
+			copy(rows, viewRows)
+			if descending {
+				for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+					rows[i], rows[j] = rows[j], rows[i]
+				}
+			}
+			if req.Method == http.MethodPost {
+				var body struct {
+					Keys []int `json:"keys"`
+				}
+				if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+					return nil, err
+				}
+				selected := []viewRow{}
+				for _, key := range body.Keys {
+					for _, row := range rows {
+						if row.Key == key {
+							selected = append(selected, row)
+						}
+					}
+				}
+				rows = selected
+			}
+			if sk := q.Get("startkey"); sk != "" {
+				startKey, err := strconv.Atoi(sk)
+				if err != nil {
+					return nil, err
+				}
+				startID := q.Get("startkey_docid")
+				selected := []viewRow{}
+				for _, row := range rows {
+					after := row.Key > startKey || (row.Key == startKey && (startID == "" || row.ID >= startID))
+					if descending {
+						after = row.Key < startKey || (row.Key == startKey && (startID == "" || row.ID <= startID))
+					}
+					if after {
+						selected = append(selected, row)
+					}
+				}
+				rows = selected
+			}
+			if skip, _ := strconv.Atoi(q.Get("skip")); skip > 0 {
+				if skip > len(rows) {
+					skip = len(rows)
+				}
+				rows = rows[skip:]
+			}
+			if limit := q.Get("limit"); limit != "" {
+				n, err := strconv.Atoi(limit)
+				if err != nil {
+					return nil, err
+				}
+				if n < len(rows) {
+					rows = rows[:n]
+				}
+			}
+			rowsJSON, err := json.Marshal(rows)
+			if err != nil {
+				return nil, err
+			}
+			body := fmt.Sprintf(`{"total_rows":%d,"offset":0,"rows":%s}`, len(viewRows), rowsJSON)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {typeJSON}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		})
+	}
+	type tst struct {
+		db       *db
+		options  map[string]interface{}
+		ids      []string
+		queries  *[]url.Values
+		requests int
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("duplicate keys across page boundaries", func(t *testing.T) interface{} {
+		queries := &[]url.Values{}
+		return tst{
+			db:       fakeView(t, queries),
+			options:  map[string]interface{}{OptionPageSize: 2},
+			ids:      []string{"a", "b", "c", "d", "e", "f"},
+			queries:  queries,
+			requests: 3,
+		}
+	})
+	tests.Add("descending", func(t *testing.T) interface{} {
+		queries := &[]url.Values{}
+		return tst{
+			db:       fakeView(t, queries),
+			options:  map[string]interface{}{OptionPageSize: 4, "descending": true},
+			ids:      []string{"f", "e", "d", "c", "b", "a"},
+			queries:  queries,
+			requests: 2,
+		}
+	})
+	tests.Add("skip and limit", func(t *testing.T) interface{} {
+		queries := &[]url.Values{}
+		return tst{
+			db:       fakeView(t, queries),
+			options:  map[string]interface{}{OptionPageSize: 2, "skip": 1, "limit": 3},
+			ids:      []string{"b", "c", "d"},
+			queries:  queries,
+			requests: 2,
+		}
+	})
+	tests.Add("start key", func(t *testing.T) interface{} {
+		queries := &[]url.Values{}
+		return tst{
+			db:       fakeView(t, queries),
+			options:  map[string]interface{}{OptionPageSize: 2, "startkey": 2, "startkey_docid": "c"},
+			ids:      []string{"c", "d", "e", "f"},
+			queries:  queries,
+			requests: 2,
+		}
+	})
+	tests.Add("keys", func(t *testing.T) interface{} {
+		queries := &[]url.Values{}
+		return tst{
+			db:       fakeView(t, queries),
+			options:  map[string]interface{}{OptionPageSize: 2, "keys": []int{4, 2, 1}},
+			ids:      []string{"f", "b", "c", "d", "a"},
+			queries:  queries,
+			requests: 2,
+		}
+	})
+	tests.Add("invalid page size", tst{
+		db:      &db{},
+		options: map[string]interface{}{OptionPageSize: -1},
+		status:  http.StatusBadRequest,
+		err:     "kivik: page size must be positive",
+	})
+	tests.Add("invalid limit", tst{
+		db:      &db{},
+		options: map[string]interface{}{OptionPageSize: 2, "limit": "3"},
+		status:  http.StatusBadRequest,
+		err:     "kivik: option 'limit' must be int or int64, not string",
+	})
+	tests.Add("multiple queries", tst{
+		db:      &db{},
+		options: map[string]interface{}{OptionPageSize: 2, "queries": []interface{}{}},
+		status:  http.StatusBadRequest,
+		err:     "kivik: paging is not supported for multiple queries",
+	})
+	tests.Add("invalid keys", tst{
+		db:      &db{},
+		options: map[string]interface{}{OptionPageSize: 2, "keys": "foo"},
+		status:  http.StatusBadRequest,
+		err:     "kivik: keys must be an array",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		rows, err := test.db.rowsQuery(context.Background(), "_design/foo/_view/bar", test.options)
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		defer rows.Close() // nolint:errcheck
+		var ids []string
+		row := new(driver.Row)
+		for {
+			if err := rows.Next(row); err != nil {
+				if err != io.EOF {
+					t.Fatal(err)
+				}
+				break
+			}
+			ids = append(ids, row.ID)
+		}
+		if d := testy.DiffInterface(test.ids, ids); d != nil {
+			t.Errorf("Unexpected IDs:\n%s", d)
+		}
+		if len(*test.queries) != test.requests {
+			t.Errorf("Expected %d requests, got %d", test.requests, len(*test.queries))
+		}
+		for i, q := range *test.queries {
+			if i > 0 && q.Get("skip") != "" {
+				t.Errorf("Request %d used skip", i)
+			}
+		}
+		if total := rows.TotalRows(); total != int64(len(viewRows)) {
+			t.Errorf("Unexpected total rows: %d", total)
+		}
+	})
+}
+
 func TestSecurity(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1892,6 +2197,100 @@ test content
 				Size:        -1,
 			},
 		},
+		{
+			name: "gzip encoded, decoded",
+			atts: &multipartAttachments{
+				meta: map[string]attMeta{
+					"foo.txt": {
+						Follows:       true,
+						ContentType:   "text/plain",
+						Size:          func() *int64 { x := int64(12); return &x }(),
+						Encoding:      "gzip",
+						EncodedLength: int64(len(gzipped("test content"))),
+					},
+				},
+				mpReader: multipart.NewReader(strings.NewReader("--xxx\r\n"+
+					"Content-Disposition: attachment; filename=\"foo.txt\"\r\n\r\n"+
+					gzipped("test content")+"\r\n--xxx--"), "xxx"),
+				enc: attEncodingDecode,
+			},
+			content: "test content",
+			expected: &driver.Attachment{
+				Filename:    "foo.txt",
+				ContentType: "text/plain",
+				Size:        12,
+			},
+		},
+		{
+			name: "gzip encoded, default",
+			atts: &multipartAttachments{
+				meta: map[string]attMeta{
+					"foo.txt": {
+						Follows:       true,
+						ContentType:   "text/plain",
+						Size:          func() *int64 { x := int64(12); return &x }(),
+						Encoding:      "gzip",
+						EncodedLength: int64(len(gzipped("test content"))),
+					},
+				},
+				mpReader: multipart.NewReader(strings.NewReader("--xxx\r\n"+
+					"Content-Disposition: attachment; filename=\"foo.txt\"\r\n\r\n"+
+					gzipped("test content")+"\r\n--xxx--"), "xxx"),
+			},
+			content: gzipped("test content"),
+			expected: &driver.Attachment{
+				Filename:        "foo.txt",
+				ContentType:     "text/plain",
+				Size:            12,
+				ContentEncoding: "gzip",
+				EncodedLength:   int64(len(gzipped("test content"))),
+			},
+		},
+		{
+			name: "gzip encoded, raw",
+			atts: &multipartAttachments{
+				meta: map[string]attMeta{
+					"foo.txt": {
+						Follows:       true,
+						ContentType:   "text/plain",
+						Size:          func() *int64 { x := int64(12); return &x }(),
+						Encoding:      "gzip",
+						EncodedLength: int64(len(gzipped("test content"))),
+					},
+				},
+				mpReader: multipart.NewReader(strings.NewReader("--xxx\r\n"+
+					"Content-Disposition: attachment; filename=\"foo.txt\"\r\n\r\n"+
+					gzipped("test content")+"\r\n--xxx--"), "xxx"),
+				enc: attEncodingRaw,
+			},
+			content: gzipped("test content"),
+			expected: &driver.Attachment{
+				Filename:        "foo.txt",
+				ContentType:     "text/plain",
+				Size:            12,
+				ContentEncoding: "gzip",
+				EncodedLength:   int64(len(gzipped("test content"))),
+			},
+		},
+		{
+			name: "invalid gzip content",
+			atts: &multipartAttachments{
+				meta: map[string]attMeta{
+					"foo.txt": {
+						Follows:  true,
+						Encoding: "gzip",
+					},
+				},
+				mpReader: multipart.NewReader(strings.NewReader(`--xxx
+Content-Disposition: attachment; filename="foo.txt"
+
+test content
+--xxx--`), "xxx"),
+				enc: attEncodingDecode,
+			},
+			status: http.StatusBadGateway,
+			err:    "gzip: invalid header",
+		},
 		{
 			name: "success, no Content-Type header, & Content-Length header",
 			atts: &multipartAttachments{
@@ -1949,6 +2348,88 @@ func TestMultipartAttachmentsClose(t *testing.T) {
 	testy.Error(t, err, atts.Close())
 }
 
+func TestParseAttStubs(t *testing.T) {
+	type tst struct {
+		input    string
+		expected map[string]attMeta
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("no attachments", tst{
+		input: `{"_id":"foo","nested":{"_attachments":{"x":{}}},"list":[1,[2,{"3":4}]]}`,
+	})
+	tests.Add("attachments", tst{
+		input: `{"_id":"foo","big":["a","b"],"_attachments":{"foo.txt":{"content_type":"text/plain","length":3,"follows":true}},"after":true}`,
+		expected: map[string]attMeta{
+			"foo.txt": {
+				ContentType: "text/plain",
+				Size:        func() *int64 { x := int64(3); return &x }(),
+				Follows:     true,
+			},
+		},
+	})
+	tests.Add("not an object", tst{
+		input: `[]`,
+		err:   "Unexpected JSON delimiter: [",
+	})
+	tests.Add("truncated", tst{
+		input: `{"_id":"foo","bar":[1,2`,
+		err:   "EOF",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		meta, err := parseAttStubs(json.NewDecoder(strings.NewReader(test.input)))
+		testy.Error(t, test.err, err)
+		if d := testy.DiffInterface(test.expected, meta); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestAttStubsReader(t *testing.T) {
+	const doc = `{"_id":"foo","_attachments":{"foo.txt":{"content_type":"text/plain","follows":true}}}`
+	t.Run("read then finish", func(t *testing.T) {
+		r := newAttStubsReader(strings.NewReader(doc))
+		content, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != doc {
+			t.Errorf("Unexpected content: %s", string(content))
+		}
+		meta, err := r.finish()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !meta["foo.txt"].Follows {
+			t.Errorf("Unexpected meta: %v", meta)
+		}
+	})
+	t.Run("finish without reading", func(t *testing.T) {
+		r := newAttStubsReader(strings.NewReader(doc))
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		meta, err := r.finish()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !meta["foo.txt"].Follows {
+			t.Errorf("Unexpected meta: %v", meta)
+		}
+	})
+	t.Run("read error", func(t *testing.T) {
+		r := newAttStubsReader(io.MultiReader(
+			strings.NewReader(`{"_id":`),
+			&mockReadCloser{ReadFunc: func(_ []byte) (int, error) {
+				return 0, errors.New("read error")
+			}},
+		))
+		_, err := r.finish()
+		testy.Error(t, "read error", err)
+	})
+}
+
 func TestPurge(t *testing.T) {
 	expectedDocMap := map[string][]string{
 		"foo": {"1-abc", "2-def"},
@@ -2126,6 +2607,20 @@ func TestAttachmentStubs(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "unchanged stub",
+			atts: &kivik.Attachments{
+				"foo.txt": &kivik.Attachment{Filename: "foo.txt", ContentType: "text/plain", Stub: true, RevPos: 2, Digest: "md5-xxx"},
+			},
+			expected: map[string]*stub{
+				"foo.txt": {
+					ContentType: "text/plain",
+					unchanged:   true,
+					revPos:      2,
+					digest:      "md5-xxx",
+				},
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
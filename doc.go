@@ -49,6 +49,25 @@ The only exceptions to the above rule are:
     disable multipart/related PUT uploads of attachments.
   - the 'NoMultipartGet' option is interpreted by the Kivik CouchDB driver to
     disable multipart/related GET downloads of attachments.
+  - the `OptionRangeOffset` and `OptionRangeLength` options set the HTTP Range
+    header when fetching attachments.
+  - the `OptionVerifyDigest` option enables MD5 verification of attachment
+    content on upload and download.
+  - the `OptionRawAttachmentEncoding` and `OptionCompressedAttachments`
+    options transfer attachments in their stored encoding, either returning
+    them undecoded, or decoding them transparently.
+  - the `OptionFindPageSize` option pages through the complete result set of a
+    Mango query, using bookmarks.
+  - the `OptionPageSize` option pages through the results of a view or
+    _all_docs query, using key ranges.
+  - the `OptionIndexProgress` option waits for a view index to build before
+    querying it, reporting the progress of the build.
+  - the `OptionTransientReplication` option starts a transient replication,
+    rather than storing a replication document.
+  - the `OptionReplicatorDB` option selects a replicator database other than
+    _replicator.
+  - the `OptionSchedulerJobs` option reads the jobs of the replication
+    scheduler, in place of listing replications.
 
 # Authentication
 
@@ -106,6 +125,12 @@ CPU usage. The simplest way to ensure efficiency is to use the NewAttachment()
 method, provided by this package. See the documentation on that method for
 proper usage.
 
+Attachments with the Stub field set are sent as stubs, and kept unchanged by
+the server, so only new or modified attachments are uploaded. Attachments of a
+document fetched without their content are already stubs, so a document may be
+fetched, modified, and stored again without re-uploading its attachments. If
+all attachments are stubs, the document is sent as plain JSON.
+
 Example:
 
 	file, _ := os.Open("/path/to/photo.jpg")
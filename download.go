@@ -0,0 +1,171 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"crypto/md5" // nolint:gosec
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// defaultDownloadRetries is the number of times an interrupted download is
+// resumed, when AttachmentDownloader.MaxRetries is unset.
+const defaultDownloadRetries = 5
+
+// AttachmentDownloader downloads attachments from a CouchDB database,
+// resuming interrupted transfers with HTTP Range requests, and verifying the
+// complete content against the attachment's MD5 digest.
+//
+// Example:
+//
+//	file, _ := os.OpenFile("video.mp4", os.O_RDWR|os.O_CREATE, 0o644)
+//	dl := &couchdb.AttachmentDownloader{DB: client.DB("media")}
+//	size, err := dl.Download(ctx, "doc_id", "video.mp4", file)
+type AttachmentDownloader struct {
+	// DB is the database from which attachments are fetched.
+	DB *kivik.DB
+
+	// MaxRetries is the number of times an interrupted download is resumed
+	// before giving up. Defaults to 5 if unset, or disables retries if
+	// negative.
+	MaxRetries int
+
+	// Options are passed to each request, i.e. to select a specific rev.
+	Options kivik.Options
+}
+
+// Download fetches the attachment to dst. If dst already contains data, as
+// from an earlier, interrupted download, the transfer resumes after the
+// existing data, which is included in the digest verification. The total size
+// of dst is returned.
+func (d *AttachmentDownloader) Download(ctx context.Context, docID, filename string, dst io.ReadWriteSeeker) (int64, error) {
+	h := md5.New() // nolint:gosec
+	offset, err := dst.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if offset > 0 {
+		if _, err := dst.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.CopyN(h, dst, offset); err != nil {
+			return 0, err
+		}
+	}
+
+	meta, err := d.DB.GetAttachmentMeta(ctx, docID, filename, d.options(nil))
+	if err != nil {
+		return offset, err
+	}
+	digest := meta.Digest
+	if meta.Size >= 0 && offset > meta.Size {
+		return offset, &kivik.Error{Status: http.StatusRequestedRangeNotSatisfiable, Err: fmt.Errorf("kivik: existing content (%d bytes) larger than attachment (%d bytes)", offset, meta.Size)}
+	}
+
+	retries := d.MaxRetries
+	if retries == 0 {
+		retries = defaultDownloadRetries
+	}
+	for attempt := 0; meta.Size < 0 || offset < meta.Size; attempt++ {
+		var done bool
+		done, offset, err = d.fetch(ctx, docID, filename, digest, meta.Size, offset, dst, h)
+		if done {
+			break
+		}
+		if ctx.Err() != nil || attempt >= retries || !retryable(err) {
+			return offset, err
+		}
+	}
+	if err := verifyDigest(digest, h); err != nil {
+		return offset, err
+	}
+	return offset, nil
+}
+
+func (d *AttachmentDownloader) options(extra kivik.Options) kivik.Options {
+	opts := make(kivik.Options, len(d.Options)+len(extra))
+	for k, v := range d.Options {
+		opts[k] = v
+	}
+	for k, v := range extra {
+		opts[k] = v
+	}
+	return opts
+}
+
+// fetch requests the attachment from offset onward, and copies it to dst and
+// h. done is true once the complete attachment has been read.
+func (d *AttachmentDownloader) fetch(ctx context.Context, docID, filename, digest string, size, offset int64, dst io.Writer, h hash.Hash) (done bool, newOffset int64, err error) {
+	var extra kivik.Options
+	if offset > 0 {
+		extra = kivik.Options{OptionRangeOffset: offset}
+	}
+	att, err := d.DB.GetAttachment(ctx, docID, filename, d.options(extra))
+	if err != nil {
+		return false, offset, err
+	}
+	defer att.Content.Close() // nolint:errcheck
+	if att.Digest != digest {
+		return false, offset, &kivik.Error{Status: http.StatusPreconditionFailed, Err: errors.New("kivik: attachment changed during download")}
+	}
+	if offset > 0 && size >= 0 && att.Size == size {
+		// The server ignored the Range header, and sent the full content.
+		if _, err := io.CopyN(io.Discard, att.Content, offset); err != nil {
+			return false, offset, err
+		}
+	}
+	w := io.MultiWriter(dst, h)
+	buf := make([]byte, 32*1024) // nolint:gomnd
+	for {
+		n, rerr := att.Content.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return false, offset, &writeError{err: err}
+			}
+			offset += int64(n)
+		}
+		switch rerr {
+		case nil:
+		case io.EOF:
+			if size >= 0 && offset < size {
+				return false, offset, io.ErrUnexpectedEOF
+			}
+			return true, offset, nil
+		default:
+			return false, offset, rerr
+		}
+	}
+}
+
+// writeError wraps errors writing to the download destination, which are
+// never retried.
+type writeError struct {
+	err error
+}
+
+func (e *writeError) Error() string { return e.err.Error() }
+func (e *writeError) Unwrap() error { return e.err }
+
+func retryable(err error) bool {
+	var werr *writeError
+	if errors.As(err, &werr) {
+		return false
+	}
+	return kivik.HTTPStatus(err) >= http.StatusInternalServerError
+}
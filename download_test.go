@@ -0,0 +1,226 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestAttachmentDownloaderDownload(t *testing.T) {
+	const (
+		content = "0123456789abcdefghij"
+		digest  = `"ZEvgbfxUBh/R5n9eu6vNWA=="` // md5 of content
+	)
+	attResponse := func(req *http.Request, etag string, failAfter int) (*http.Response, error) {
+		body := content
+		status := http.StatusOK
+		if rng := req.Header.Get("Range"); rng != "" {
+			offset, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			if err != nil {
+				return nil, err
+			}
+			body = content[offset:]
+			status = http.StatusPartialContent
+		}
+		var rc io.ReadCloser = io.NopCloser(strings.NewReader(body))
+		if failAfter > 0 {
+			rc = io.NopCloser(io.MultiReader(
+				strings.NewReader(body[:failAfter]),
+				&mockReadCloser{ReadFunc: func(_ []byte) (int, error) {
+					return 0, errors.New("connection reset")
+				}},
+			))
+		}
+		if req.Method == http.MethodHead {
+			rc = io.NopCloser(strings.NewReader(""))
+		}
+		return &http.Response{
+			StatusCode:    status,
+			Header:        http.Header{"ETag": {etag}, "Content-Type": {"text/plain"}},
+			ContentLength: int64(len(body)),
+			Body:          rc,
+			Request:       req,
+		}, nil
+	}
+	type tst struct {
+		fn       func(*http.Request) (*http.Response, error)
+		existing string
+		retries  int
+		size     int64
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("complete download", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet && req.Header.Get("Range") != "" {
+				return nil, errors.New("unexpected Range header")
+			}
+			return attResponse(req, digest, 0)
+		},
+		size: 20,
+	})
+	tests.Add("resume after interruption", func(t *testing.T) interface{} {
+		var gets int
+		return tst{
+			fn: func(req *http.Request) (*http.Response, error) {
+				if req.Method == http.MethodHead {
+					return attResponse(req, digest, 0)
+				}
+				gets++
+				switch gets {
+				case 1:
+					return attResponse(req, digest, 8)
+				case 2:
+					if rng := req.Header.Get("Range"); rng != "bytes=8-" {
+						return nil, fmt.Errorf("Unexpected Range: %s", rng)
+					}
+				}
+				return attResponse(req, digest, 0)
+			},
+			size: 20,
+		}
+	})
+	tests.Add("resume existing content", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet {
+				if rng := req.Header.Get("Range"); rng != "bytes=5-" {
+					return nil, fmt.Errorf("Unexpected Range: %s", rng)
+				}
+			}
+			return attResponse(req, digest, 0)
+		},
+		existing: "01234",
+		size:     20,
+	})
+	tests.Add("range ignored by server", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			req.Header.Del("Range")
+			return attResponse(req, digest, 0)
+		},
+		existing: "01234",
+		size:     20,
+	})
+	tests.Add("already complete", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodGet {
+				return nil, errors.New("unexpected GET")
+			}
+			return attResponse(req, digest, 0)
+		},
+		existing: content,
+		size:     20,
+	})
+	tests.Add("digest mismatch", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			return attResponse(req, `"ENGoH7oK8V9R3BMnfDHZmw=="`, 0)
+		},
+		size:   20,
+		status: http.StatusBadGateway,
+		err:    "kivik: attachment digest mismatch: expected ENGoH7oK8V9R3BMnfDHZmw==, got ZEvgbfxUBh/R5n9eu6vNWA==",
+	})
+	tests.Add("corrupt existing content", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			return attResponse(req, digest, 0)
+		},
+		existing: "xxxxx",
+		size:     20,
+		status:   http.StatusBadGateway,
+		err:      "kivik: attachment digest mismatch",
+	})
+	tests.Add("attachment changed", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodHead {
+				return attResponse(req, digest, 0)
+			}
+			return attResponse(req, `"ENGoH7oK8V9R3BMnfDHZmw=="`, 0)
+		},
+		status: http.StatusPreconditionFailed,
+		err:    "kivik: attachment changed during download",
+	})
+	tests.Add("retries exhausted", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodHead {
+				return attResponse(req, digest, 0)
+			}
+			return attResponse(req, digest, 1)
+		},
+		retries: 2,
+		size:    3,
+		status:  http.StatusInternalServerError,
+		err:     "connection reset",
+	})
+	tests.Add("not found", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Body:       io.NopCloser(strings.NewReader("")),
+				Request:    req,
+			}, nil
+		},
+		status: http.StatusNotFound,
+		err:    "Not Found",
+	})
+	tests.Add("existing content too large", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			return attResponse(req, digest, 0)
+		},
+		existing: content + "extra",
+		size:     25,
+		status:   http.StatusRequestedRangeNotSatisfiable,
+		err:      `kivik: existing content \(25 bytes\) larger than attachment \(20 bytes\)`,
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		f, err := os.CreateTemp(t.TempDir(), "download-*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close() // nolint:errcheck
+		if _, err := f.WriteString(test.existing); err != nil {
+			t.Fatal(err)
+		}
+		dl := &AttachmentDownloader{
+			DB:         newTestKivikDB(t, test.fn),
+			MaxRetries: test.retries,
+		}
+		size, err := dl.Download(context.Background(), "foo", "foo.txt", f)
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		if size != test.size {
+			t.Errorf("Unexpected size: %d", size)
+		}
+		if err != nil {
+			return
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		result, err := io.ReadAll(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(result) != content {
+			t.Errorf("Unexpected content: %s", string(result))
+		}
+	})
+}
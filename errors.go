@@ -22,3 +22,26 @@ import (
 func missingArg(arg string) error {
 	return &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: %s required", arg)}
 }
+
+// errorStatus maps the error strings CouchDB reports for individual documents,
+// such as in _bulk_docs or _bulk_get results, to their HTTP status codes.
+func errorStatus(err string) int {
+	switch err {
+	case "bad_request", "illegal_docid", "doc_validation", "invalid_rev":
+		return http.StatusBadRequest
+	case "unauthorized":
+		return http.StatusUnauthorized
+	case "forbidden":
+		return http.StatusForbidden
+	case "not_found":
+		return http.StatusNotFound
+	case "conflict":
+		return http.StatusConflict
+	case "precondition_failed", "file_exists":
+		return http.StatusPreconditionFailed
+	case "too_large", "document_too_large", "attachment_too_large":
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
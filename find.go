@@ -13,13 +13,17 @@
 package couchdb
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"path"
 
 	"github.com/go-kivik/couchdb/v4/chttp"
+	kivik "github.com/go-kivik/kivik/v4"
 	"github.com/go-kivik/kivik/v4/driver"
 )
 
@@ -83,12 +87,104 @@ func (d *db) DeleteIndex(ctx context.Context, ddoc, name string, opts map[string
 	return err
 }
 
+// queryValidator is implemented by queries which can be validated before they
+// are sent to the server.
+type queryValidator interface {
+	Validate() error
+}
+
+// validateQuery validates query, if it supports validation.
+func validateQuery(query interface{}) error {
+	if v, ok := query.(queryValidator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
 func (d *db) Find(ctx context.Context, query interface{}, opts map[string]interface{}) (driver.Rows, error) {
+	if err := validateQuery(query); err != nil {
+		return nil, err
+	}
 	reqPath := "_find"
 	if part, ok := opts[OptionPartition].(string); ok {
 		delete(opts, OptionPartition)
 		reqPath = path.Join("_partition", part, reqPath)
 	}
+	pageSize, paged, err := int64Option(opts, OptionFindPageSize)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := executionStatsOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	if stats != nil {
+		q, err := findQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		q["execution_stats"] = true
+		query = q
+	}
+	if !paged {
+		results, err := d.find(ctx, reqPath, query)
+		if err != nil {
+			return nil, err
+		}
+		if stats != nil {
+			results.(*rows).meta.executionStats = stats
+		}
+		return results, nil
+	}
+	if pageSize <= 0 {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: find page size must be positive")}
+	}
+	q, err := findQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := findLimit(q)
+	if err != nil {
+		return nil, err
+	}
+	r := &pagedFindRows{
+		ctx:      ctx,
+		stats:    stats,
+		pageSize: pageSize,
+		limit:    limit,
+		fetch: func(ctx context.Context, bookmark string, limit int64) (driver.Rows, error) {
+			q["limit"] = limit
+			if bookmark != "" {
+				// The bookmark already accounts for any skipped results.
+				delete(q, "skip")
+				q["bookmark"] = bookmark
+			}
+			return d.find(ctx, reqPath, q)
+		},
+	}
+	first, err := r.fetch(ctx, "", r.pageLimit())
+	if err != nil {
+		return nil, err
+	}
+	r.cur = first.(*rows)
+	return r, nil
+}
+
+// findLimit returns the limit of a Mango query, or -1 if it has none.
+func findLimit(q map[string]interface{}) (int64, error) {
+	i, ok := q["limit"]
+	if !ok {
+		return -1, nil
+	}
+	if n, ok := i.(json.Number); ok {
+		if limit, err := n.Int64(); err == nil && limit >= 0 {
+			return limit, nil
+		}
+	}
+	return 0, &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: query limit must be a non-negative integer")}
+}
+
+func (d *db) find(ctx context.Context, reqPath string, query interface{}) (driver.Rows, error) {
 	options := &chttp.Options{
 		GetBody: chttp.BodyEncoder(query),
 		Header: http.Header{
@@ -105,6 +201,150 @@ func (d *db) Find(ctx context.Context, query interface{}, opts map[string]interf
 	return newFindRows(ctx, resp.Body), nil
 }
 
+// ExecutionStats are the statistics reported by CouchDB for the execution of
+// a Mango query, when requested with OptionExecutionStats, or with
+// execution_stats in the query.
+type ExecutionStats struct {
+	TotalKeysExamined       int64   `json:"total_keys_examined"`
+	TotalDocsExamined       int64   `json:"total_docs_examined"`
+	TotalQuorumDocsExamined int64   `json:"total_quorum_docs_examined"`
+	ResultsReturned         int64   `json:"results_returned"`
+	ExecutionTimeMs         float64 `json:"execution_time_ms"`
+}
+
+// add accumulates the stats of another page of results into s.
+func (s *ExecutionStats) add(page *ExecutionStats) {
+	s.TotalKeysExamined += page.TotalKeysExamined
+	s.TotalDocsExamined += page.TotalDocsExamined
+	s.TotalQuorumDocsExamined += page.TotalQuorumDocsExamined
+	s.ResultsReturned += page.ResultsReturned
+	s.ExecutionTimeMs += page.ExecutionTimeMs
+}
+
+func executionStatsOption(opts map[string]interface{}) (*ExecutionStats, error) {
+	i, ok := opts[OptionExecutionStats]
+	if !ok {
+		return nil, nil
+	}
+	stats, ok := i.(*ExecutionStats)
+	if !ok || stats == nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: option '%s' must be a non-nil *ExecutionStats, not %T", OptionExecutionStats, i)}
+	}
+	delete(opts, OptionExecutionStats)
+	return stats, nil
+}
+
+// findQuery converts a Mango query to a map, so that its paging parameters
+// may be altered.
+func findQuery(query interface{}) (map[string]interface{}, error) {
+	var body []byte
+	switch t := query.(type) {
+	case string:
+		body = []byte(t)
+	case []byte:
+		body = t
+	case json.RawMessage:
+		body = t
+	default:
+		var err error
+		body, err = json.Marshal(query)
+		if err != nil {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+		}
+	}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var q map[string]interface{}
+	if err := dec.Decode(&q); err != nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	if q == nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: query must be a JSON object")}
+	}
+	return q, nil
+}
+
+// pagedFindRows iterates over the results of a Mango query, page by page,
+// re-issuing the query with the bookmark of each page until an empty page is
+// returned, or the limit of the query is reached.
+type pagedFindRows struct {
+	ctx      context.Context
+	cur      *rows
+	fetch    func(ctx context.Context, bookmark string, limit int64) (driver.Rows, error)
+	pageSize int64
+	// limit is the number of rows remaining to be returned, or -1 for no
+	// limit.
+	limit int64
+	// stats, if set, accumulates the execution stats of each page.
+	stats *ExecutionStats
+
+	// count is the number of rows read from the current page.
+	count int
+}
+
+var (
+	_ driver.Rows       = &pagedFindRows{}
+	_ driver.Bookmarker = &pagedFindRows{}
+	_ driver.RowsWarner = &pagedFindRows{}
+)
+
+// pageLimit returns the limit of the next page.
+func (r *pagedFindRows) pageLimit() int64 {
+	if r.limit >= 0 && r.limit < r.pageSize {
+		return r.limit
+	}
+	return r.pageSize
+}
+
+func (r *pagedFindRows) Next(row *driver.Row) error {
+	for {
+		err := r.cur.Next(row)
+		if err != io.EOF {
+			if err == nil {
+				r.count++
+				if r.limit > 0 {
+					r.limit--
+				}
+			}
+			return err
+		}
+		if r.stats != nil {
+			if page := r.cur.ExecutionStats(); page != nil {
+				r.stats.add(page)
+			}
+		}
+		bookmark := r.cur.Bookmark()
+		if r.count == 0 || bookmark == "" || r.limit == 0 {
+			return io.EOF
+		}
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
+		_ = r.cur.Close()
+		next, err := r.fetch(r.ctx, bookmark, r.pageLimit())
+		if err != nil {
+			return err
+		}
+		r.cur = next.(*rows)
+		r.count = 0
+	}
+}
+
+func (r *pagedFindRows) Close() error {
+	return r.cur.Close()
+}
+
+// ExecutionStats returns the accumulated execution stats of all pages, if
+// requested with OptionExecutionStats.
+func (r *pagedFindRows) ExecutionStats() *ExecutionStats { return r.stats }
+
+// Bookmark returns the bookmark of the current page.
+func (r *pagedFindRows) Bookmark() string  { return r.cur.Bookmark() }
+func (r *pagedFindRows) Warning() string   { return r.cur.Warning() }
+func (r *pagedFindRows) UpdateSeq() string { return "" }
+func (r *pagedFindRows) Offset() int64     { return 0 }
+func (r *pagedFindRows) TotalRows() int64  { return 0 }
+
 type queryPlan struct {
 	DBName   string                 `json:"dbname"`
 	Index    map[string]interface{} `json:"index"`
@@ -133,6 +373,9 @@ func (f *fields) UnmarshalJSON(data []byte) error {
 }
 
 func (d *db) Explain(ctx context.Context, query interface{}, opts map[string]interface{}) (*driver.QueryPlan, error) {
+	if err := validateQuery(query); err != nil {
+		return nil, err
+	}
 	reqPath := "_explain"
 	if part, ok := opts[OptionPartition].(string); ok {
 		delete(opts, OptionPartition)
@@ -24,6 +24,7 @@ import (
 
 	"gitlab.com/flimzy/testy"
 
+	"github.com/go-kivik/couchdb/v4/mango"
 	"github.com/go-kivik/kivik/v4/driver"
 )
 
@@ -85,6 +86,26 @@ func TestExplain(t *testing.T) {
 			status: http.StatusBadGateway,
 			err:    `Post "?http://example.com/testdb/_explain"?: success`,
 		},
+		{
+			name:   "invalid typed query",
+			db:     &db{},
+			query:  &mango.Query{},
+			status: http.StatusBadRequest,
+			err:    "kivik: invalid query: selector required",
+		},
+		{
+			name: "typed query",
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				defer req.Body.Close() // nolint: errcheck
+				if d := testy.DiffAsJSON(map[string]interface{}{"selector": map[string]interface{}{"_id": map[string]string{"$gt": "a"}}}, req.Body); d != nil {
+					return nil, fmt.Errorf("Unexpected body:\n%s", d)
+				}
+				return nil, errors.New("success")
+			}),
+			query:  &mango.Query{Selector: mango.Gt("_id", "a")},
+			status: http.StatusBadGateway,
+			err:    "success",
+		},
 		{
 			name: "partitioned request",
 			db:   newTestDB(nil, errors.New("expected")),
@@ -444,3 +465,306 @@ func TestFind(t *testing.T) {
 		})
 	}
 }
+
+func TestFindPaged(t *testing.T) {
+	// pages maps the bookmark of each request to the response body.
+	pages := map[string]string{
+		"":   `{"docs":[{"_id":"a"},{"_id":"b"}],"bookmark":"b1"}`,
+		"b1": `{"docs":[{"_id":"c"}],"bookmark":"b2"}`,
+		"b2": `{"docs":[],"bookmark":"b3"}`,
+	}
+	pagedDB := func(t *testing.T, reqs *[]map[string]interface{}) *db {
+		t.Helper()
+		return newCustomDB(func(req *http.Request) (*http.Response, error) {
+			var q map[string]interface{}
+			if err := json.NewDecoder(req.Body).Decode(&q); err != nil {
+				return nil, err
+			}
+			*reqs = append(*reqs, q)
+			bookmark, _ := q["bookmark"].(string)
+			body, ok := pages[bookmark]
+			if !ok {
+				return nil, fmt.Errorf("unexpected bookmark: %s", bookmark)
+			}
+			var page struct {
+				Docs     []json.RawMessage `json:"docs"`
+				Bookmark string            `json:"bookmark"`
+			}
+			_ = json.Unmarshal([]byte(body), &page)
+			if limit, ok := q["limit"].(float64); ok && int(limit) < len(page.Docs) {
+				page.Docs = page.Docs[:int(limit)]
+				content, _ := json.Marshal(page)
+				body = string(content)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       io.NopCloser(strings.NewReader(body)),
+				Request:    req,
+			}, nil
+		})
+	}
+	type tst struct {
+		db       *db
+		query    interface{}
+		opts     map[string]interface{}
+		ids      []string
+		reqs     *[]map[string]interface{}
+		expected []map[string]interface{}
+		status   int
+		err      string
+		nextErr  string
+
+		// cancelAfter cancels the context after reading this many rows.
+		cancelAfter int
+	}
+	tests := testy.NewTable()
+	tests.Add("all pages", func(t *testing.T) interface{} {
+		reqs := &[]map[string]interface{}{}
+		return tst{
+			db:    pagedDB(t, reqs),
+			query: map[string]interface{}{"selector": map[string]string{"type": "x"}, "skip": 5},
+			opts:  map[string]interface{}{OptionFindPageSize: 2},
+			ids:   []string{"a", "b", "c"},
+			reqs:  reqs,
+			expected: []map[string]interface{}{
+				{"selector": map[string]interface{}{"type": "x"}, "skip": float64(5), "limit": float64(2)},
+				{"selector": map[string]interface{}{"type": "x"}, "limit": float64(2), "bookmark": "b1"},
+				{"selector": map[string]interface{}{"type": "x"}, "limit": float64(2), "bookmark": "b2"},
+			},
+		}
+	})
+	tests.Add("limit across pages", func(t *testing.T) interface{} {
+		reqs := &[]map[string]interface{}{}
+		return tst{
+			db:    pagedDB(t, reqs),
+			query: map[string]interface{}{"selector": map[string]string{"type": "x"}, "limit": 3},
+			opts:  map[string]interface{}{OptionFindPageSize: 2},
+			ids:   []string{"a", "b", "c"},
+			reqs:  reqs,
+			expected: []map[string]interface{}{
+				{"selector": map[string]interface{}{"type": "x"}, "limit": float64(2)},
+				{"selector": map[string]interface{}{"type": "x"}, "limit": float64(1), "bookmark": "b1"},
+			},
+		}
+	})
+	tests.Add("limit smaller than page size", func(t *testing.T) interface{} {
+		reqs := &[]map[string]interface{}{}
+		return tst{
+			db:    pagedDB(t, reqs),
+			query: `{"selector":{"type":"x"},"limit":1}`,
+			opts:  map[string]interface{}{OptionFindPageSize: 2},
+			ids:   []string{"a"},
+			reqs:  reqs,
+			expected: []map[string]interface{}{
+				{"selector": map[string]interface{}{"type": "x"}, "limit": float64(1)},
+			},
+		}
+	})
+	tests.Add("invalid limit", tst{
+		db:     &db{},
+		query:  map[string]interface{}{"limit": "1"},
+		opts:   map[string]interface{}{OptionFindPageSize: 2},
+		status: http.StatusBadRequest,
+		err:    "kivik: query limit must be a non-negative integer",
+	})
+	tests.Add("string query", func(t *testing.T) interface{} {
+		reqs := &[]map[string]interface{}{}
+		return tst{
+			db:    pagedDB(t, reqs),
+			query: `{"selector":{"type":"x"}}`,
+			opts:  map[string]interface{}{OptionFindPageSize: int64(2)},
+			ids:   []string{"a", "b", "c"},
+			reqs:  reqs,
+		}
+	})
+	tests.Add("partitioned", tst{
+		db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+			return nil, errors.New(req.URL.Path)
+		}),
+		query: map[string]interface{}{},
+		opts: map[string]interface{}{
+			OptionPartition:    "x2",
+			OptionFindPageSize: 2,
+		},
+		status: http.StatusBadGateway,
+		err:    "/testdb/_partition/x2/_find",
+	})
+	tests.Add("invalid page size", tst{
+		db:     &db{},
+		query:  map[string]interface{}{},
+		opts:   map[string]interface{}{OptionFindPageSize: 0},
+		status: http.StatusBadRequest,
+		err:    "kivik: find page size must be positive",
+	})
+	tests.Add("invalid page size type", tst{
+		db:     &db{},
+		query:  map[string]interface{}{},
+		opts:   map[string]interface{}{OptionFindPageSize: "2"},
+		status: http.StatusBadRequest,
+		err:    "kivik: option 'kivik:find-page-size' must be int or int64, not string",
+	})
+	tests.Add("non-object query", tst{
+		db:     &db{},
+		query:  `[]`,
+		opts:   map[string]interface{}{OptionFindPageSize: 2},
+		status: http.StatusBadRequest,
+		err:    `json: cannot unmarshal array into Go value of type map\[string\]interface \{\}`,
+	})
+	tests.Add("error fetching second page", func(t *testing.T) interface{} {
+		var requests int
+		return tst{
+			db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+				requests++
+				if requests > 1 {
+					return nil, errors.New("page failed")
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body:       io.NopCloser(strings.NewReader(pages[""])),
+					Request:    req,
+				}, nil
+			}),
+			query:   map[string]interface{}{},
+			opts:    map[string]interface{}{OptionFindPageSize: 2},
+			ids:     []string{"a", "b"},
+			nextErr: `Post "?http://example.com/testdb/_find"?: page failed`,
+		}
+	})
+
+	tests.Add("context cancelled between pages", func(t *testing.T) interface{} {
+		reqs := &[]map[string]interface{}{}
+		return tst{
+			db:          pagedDB(t, reqs),
+			query:       map[string]interface{}{},
+			opts:        map[string]interface{}{OptionFindPageSize: 2},
+			ids:         []string{"a", "b"},
+			cancelAfter: 2,
+			nextErr:     "context canceled",
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		rows, err := test.db.Find(ctx, test.query, test.opts)
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		defer rows.Close() // nolint:errcheck
+		var ids []string
+		row := new(driver.Row)
+		for {
+			if test.cancelAfter > 0 && len(ids) == test.cancelAfter {
+				cancel()
+			}
+			err = rows.Next(row)
+			if err != nil {
+				break
+			}
+			var doc struct {
+				ID string `json:"_id"`
+			}
+			if err := json.NewDecoder(row.Doc).Decode(&doc); err != nil {
+				t.Fatal(err)
+			}
+			ids = append(ids, doc.ID)
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		testy.ErrorRE(t, test.nextErr, err)
+		if d := testy.DiffInterface(test.ids, ids); d != nil {
+			t.Errorf("Unexpected IDs:\n%s", d)
+		}
+		if test.expected != nil {
+			if d := testy.DiffInterface(test.expected, *test.reqs); d != nil {
+				t.Errorf("Unexpected requests:\n%s", d)
+			}
+		}
+	})
+}
+
+func TestFindExecutionStats(t *testing.T) {
+	statsPage := func(bookmark string, docs int) string {
+		ids := make([]string, docs)
+		for i := range ids {
+			ids[i] = fmt.Sprintf(`{"_id":"%s%d"}`, bookmark, i)
+		}
+		return fmt.Sprintf(`{"docs":[%s],"bookmark":"%s1","execution_stats":{"total_keys_examined":%d,"total_docs_examined":%d,"results_returned":%d,"execution_time_ms":1}}`,
+			strings.Join(ids, ","), bookmark, docs*2, docs, docs)
+	}
+	type tst struct {
+		db       *db
+		opts     map[string]interface{}
+		expected *ExecutionStats
+		status   int
+		err      string
+	}
+	statsDB := newCustomDB(func(req *http.Request) (*http.Response, error) {
+		var q struct {
+			ExecutionStats bool   `json:"execution_stats"`
+			Bookmark       string `json:"bookmark"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&q); err != nil {
+			return nil, err
+		}
+		if !q.ExecutionStats {
+			return nil, errors.New("execution_stats not requested")
+		}
+		docs := map[string]int{"": 2, "1": 1}[q.Bookmark]
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(statsPage(q.Bookmark, docs))),
+			Request:    req,
+		}, nil
+	})
+	tests := testy.NewTable()
+	tests.Add("single request", tst{
+		db:   statsDB,
+		opts: map[string]interface{}{},
+		expected: &ExecutionStats{
+			TotalKeysExamined: 4,
+			TotalDocsExamined: 2,
+			ResultsReturned:   2,
+			ExecutionTimeMs:   1,
+		},
+	})
+	tests.Add("paged", tst{
+		db:   statsDB,
+		opts: map[string]interface{}{OptionFindPageSize: 2},
+		expected: &ExecutionStats{
+			TotalKeysExamined: 6,
+			TotalDocsExamined: 3,
+			ResultsReturned:   3,
+			ExecutionTimeMs:   3,
+		},
+	})
+	tests.Add("invalid type", tst{
+		db:     statsDB,
+		opts:   map[string]interface{}{OptionExecutionStats: ExecutionStats{}},
+		status: http.StatusBadRequest,
+		err:    "kivik: option 'kivik:execution-stats' must be a non-nil *ExecutionStats, not couchdb.ExecutionStats",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		stats := new(ExecutionStats)
+		if _, ok := test.opts[OptionExecutionStats]; !ok {
+			test.opts[OptionExecutionStats] = stats
+		}
+		rows, err := test.db.Find(context.Background(), `{"selector":{}}`, test.opts)
+		testy.StatusError(t, test.err, test.status, err)
+		defer rows.Close() // nolint:errcheck
+		for {
+			if err := rows.Next(&driver.Row{}); err != nil {
+				if err != io.EOF {
+					t.Fatal(err)
+				}
+				break
+			}
+		}
+		if d := testy.DiffInterface(test.expected, stats); d != nil {
+			t.Error(d)
+		}
+	})
+}
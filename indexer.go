@@ -0,0 +1,176 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kivik/couchdb/v4/chttp"
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// IndexProgress is the progress of a view index build, as reported to the
+// OptionIndexProgress callback.
+type IndexProgress struct {
+	// DDoc is the design document ID, with the _design/ prefix.
+	DDoc string
+
+	// Tasks is the number of indexer tasks still running. Clustered servers
+	// run one task per shard. Tasks may be 0 before the server has started
+	// the build, and is 0 once the build is complete.
+	Tasks int
+
+	// ChangesDone and TotalChanges are the totals over all running tasks.
+	ChangesDone  int64
+	TotalChanges int64
+
+	// Progress is the completion of the build, as a percentage.
+	Progress int
+}
+
+// indexPollInterval is the interval at which the design document info and
+// _active_tasks are polled while waiting for an index build.
+var indexPollInterval = time.Second
+
+// WarmView triggers a build of the view index of ddoc and view, and waits for
+// the build to complete, so that later queries are not blocked by it. progress,
+// if not nil, is called as described for OptionIndexProgress. Views in the same
+// design document share an index, so warming one view warms them all.
+func WarmView(ctx context.Context, db *kivik.DB, ddoc, view string, progress func(IndexProgress)) error {
+	if progress == nil {
+		progress = func(IndexProgress) {}
+	}
+	rows := db.Query(ctx, ddoc, view, kivik.Options{
+		OptionIndexProgress: progress,
+		"limit":             0,
+	})
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+func indexProgressOption(opts map[string]interface{}) (func(IndexProgress), error) {
+	i, ok := opts[OptionIndexProgress]
+	if !ok {
+		return nil, nil
+	}
+	progress, ok := i.(func(IndexProgress))
+	if !ok || progress == nil {
+		return nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: option '%s' must be a non-nil func(couchdb.IndexProgress), not %T", OptionIndexProgress, i)}
+	}
+	delete(opts, OptionIndexProgress)
+	return progress, nil
+}
+
+// designDocInfo is the response of GET /{db}/_design/{ddoc}/_info.
+type designDocInfo struct {
+	ViewIndex struct {
+		UpdaterRunning bool       `json:"updater_running"`
+		UpdateSeq      sequenceID `json:"update_seq"`
+	} `json:"view_index"`
+}
+
+// waitForIndex triggers an update of the view at reqPath, without waiting for
+// it, and then polls the _info of ddoc until its updater is no longer running,
+// and its index has reached the update sequence of the database at the time
+// of the trigger. The latter guards against polling before the updater has
+// started. _active_tasks is polled in between, for the progress of the build,
+// unless the user is not permitted to read it.
+func (d *db) waitForIndex(ctx context.Context, reqPath, ddoc string, progress func(IndexProgress)) error {
+	var dbInfo struct {
+		UpdateSeq sequenceID `json:"update_seq"`
+	}
+	if err := d.Client.DoJSON(ctx, http.MethodGet, d.dbName, nil, &dbInfo); err != nil {
+		return err
+	}
+	target, targetOK := seqNumber(dbInfo.UpdateSeq)
+	opts := &chttp.Options{
+		Query: url.Values{"update": {"lazy"}, "limit": {"0"}},
+	}
+	if _, err := d.Client.DoError(ctx, http.MethodGet, d.path(reqPath), opts); err != nil {
+		return err
+	}
+	ddoc = "_design/" + strings.TrimPrefix(ddoc, "_design/")
+	infoPath := d.path(chttp.EncodeDocID(ddoc) + "/_info")
+	// Active tasks report the unescaped database name.
+	dbName, _ := url.PathUnescape(d.dbName)
+	// tasksDenied is set once _active_tasks has been refused, as it requires
+	// admin access. The progress is then estimated from _info alone.
+	var tasksDenied bool
+	for {
+		var info designDocInfo
+		if err := d.Client.DoJSON(ctx, http.MethodGet, infoPath, nil, &info); err != nil {
+			return err
+		}
+		indexed, indexedOK := seqNumber(info.ViewIndex.UpdateSeq)
+		if !info.ViewIndex.UpdaterRunning && (!targetOK || !indexedOK || indexed >= target) {
+			progress(IndexProgress{DDoc: ddoc, Progress: 100})
+			return nil
+		}
+		var tasks []*activeTask
+		if !tasksDenied {
+			var err error
+			tasks, err = d.client.activeTasks(ctx)
+			switch status := kivik.HTTPStatus(err); {
+			case err == nil:
+			case status == http.StatusUnauthorized, status == http.StatusForbidden:
+				tasksDenied = true
+			default:
+				return err
+			}
+		}
+		p := IndexProgress{DDoc: ddoc}
+		for _, task := range tasks {
+			if task.Type != "indexer" || task.DesignDocument != ddoc || taskDBName(task.Database) != dbName {
+				continue
+			}
+			p.Tasks++
+			p.ChangesDone += task.ChangesDone
+			p.TotalChanges += task.TotalChanges
+		}
+		switch {
+		case p.TotalChanges > 0:
+			p.Progress = int(p.ChangesDone * 100 / p.TotalChanges) // nolint:gomnd
+		case indexedOK && targetOK && target > 0:
+			p.Progress = int(indexed * 100 / target) // nolint:gomnd
+		}
+		progress(p)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(indexPollInterval):
+		}
+	}
+}
+
+// taskDBName returns the name of the database of an active task. Clustered
+// servers report shard names of the form shards/<range>/<db>.<suffix>.
+func taskDBName(name string) string {
+	if !strings.HasPrefix(name, "shards/") {
+		return name
+	}
+	name = strings.TrimPrefix(name, "shards/")
+	if i := strings.Index(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
@@ -0,0 +1,268 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestWarmView(t *testing.T) {
+	defer func(d time.Duration) { indexPollInterval = d }(indexPollInterval)
+	indexPollInterval = time.Millisecond
+
+	const (
+		shard1 = `{"type":"indexer","database":"shards/00000000-7fffffff/testdb.1565","design_document":"_design/app","changes_done":%d,"total_changes":100}`
+		shard2 = `{"type":"indexer","database":"shards/80000000-ffffffff/testdb.1565","design_document":"_design/app","changes_done":%d,"total_changes":100}`
+		other  = `{"type":"indexer","database":"shards/00000000-7fffffff/otherdb.1565","design_document":"_design/app","changes_done":0,"total_changes":100}`
+		info   = `{"name":"app","view_index":{"updater_running":%t,"update_seq":%d}}`
+	)
+	jsonResponse := func(body string) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       Body(body),
+		}, nil
+	}
+	// server returns the successive responses of infos and tasks to the
+	// design document info and _active_tasks polls.
+	server := func(infos, tasks []string) func(*http.Request) (*http.Response, error) {
+		var infoPolls, taskPolls int
+		return func(req *http.Request) (*http.Response, error) {
+			switch req.URL.Path {
+			case "/testdb":
+				return jsonResponse(`{"db_name":"testdb","update_seq":"100-g1AAAAFTeJzLYWBg4MhgTmHgzcvPy09JdcjLz8gvLskBCeexAEmGBiD1HwiyEhlwqEtkSKqHKMgCAIT2GV4"}`)
+			case "/testdb/_design/app/_view/by-name":
+				if infoPolls == 0 && req.URL.Query().Get("update") != "lazy" {
+					return nil, errors.New("expected update=lazy")
+				}
+				return jsonResponse(`{"total_rows":0,"offset":0,"rows":[]}`)
+			case "/testdb/_design/app/_info":
+				infoPolls++
+				return jsonResponse(infos[infoPolls-1])
+			case "/_active_tasks":
+				taskPolls++
+				return jsonResponse(tasks[taskPolls-1])
+			}
+			return nil, fmt.Errorf("unexpected request: %s", req.URL.Path)
+		}
+	}
+	type tst struct {
+		fn       func(*http.Request) (*http.Response, error)
+		ctx      context.Context
+		progress []IndexProgress
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("trigger failure", tst{
+		fn: func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("trigger failed")
+		},
+		status: http.StatusBadGateway,
+		err:    "trigger failed",
+	})
+	tests.Add("build in progress", tst{
+		fn: server(
+			[]string{
+				fmt.Sprintf(info, true, 40),
+				fmt.Sprintf(info, true, 90),
+				fmt.Sprintf(info, false, 100),
+			},
+			[]string{
+				"[" + fmt.Sprintf(shard1, 10) + "," + fmt.Sprintf(shard2, 30) + "," + other + "]",
+				"[" + fmt.Sprintf(shard1, 90) + "]",
+			},
+		),
+		progress: []IndexProgress{
+			{DDoc: "_design/app", Tasks: 2, ChangesDone: 40, TotalChanges: 200, Progress: 20},
+			{DDoc: "_design/app", Tasks: 1, ChangesDone: 90, TotalChanges: 100, Progress: 90},
+			{DDoc: "_design/app", Progress: 100},
+		},
+	})
+	tests.Add("build not yet started", tst{
+		fn: server(
+			[]string{
+				fmt.Sprintf(info, false, 0),
+				fmt.Sprintf(info, true, 50),
+				fmt.Sprintf(info, false, 100),
+			},
+			[]string{
+				"[" + other + "]",
+				"[]",
+			},
+		),
+		progress: []IndexProgress{
+			{DDoc: "_design/app"},
+			{DDoc: "_design/app", Progress: 50},
+			{DDoc: "_design/app", Progress: 100},
+		},
+	})
+	tests.Add("index up to date", tst{
+		fn: server([]string{fmt.Sprintf(info, false, 100)}, nil),
+		progress: []IndexProgress{
+			{DDoc: "_design/app", Progress: 100},
+		},
+	})
+	tests.Add("info failure", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/testdb/_design/app/_info" {
+				return nil, errors.New("info failed")
+			}
+			return server(nil, nil)(req)
+		},
+		status: http.StatusBadGateway,
+		err:    "info failed",
+	})
+	tests.Add("active tasks failure", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/_active_tasks" {
+				return nil, errors.New("tasks failed")
+			}
+			return server([]string{fmt.Sprintf(info, true, 0)}, nil)(req)
+		},
+		status: http.StatusBadGateway,
+		err:    "tasks failed",
+	})
+	tests.Add("active tasks forbidden", func(t *testing.T) interface{} {
+		var denied int
+		fn := server([]string{
+			fmt.Sprintf(info, true, 50),
+			fmt.Sprintf(info, true, 80),
+			fmt.Sprintf(info, false, 100),
+		}, nil)
+		return tst{
+			fn: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Path == "/_active_tasks" {
+					if denied++; denied > 1 {
+						return nil, errors.New("_active_tasks polled after it was denied")
+					}
+					return &http.Response{
+						StatusCode: http.StatusForbidden,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       Body(`{"error":"forbidden","reason":"You are not a server admin."}`),
+					}, nil
+				}
+				return fn(req)
+			},
+			progress: []IndexProgress{
+				{DDoc: "_design/app", Progress: 50},
+				{DDoc: "_design/app", Progress: 80},
+				{DDoc: "_design/app", Progress: 100},
+			},
+		}
+	})
+	tests.Add("context cancelled", func(t *testing.T) interface{} {
+		ctx, cancel := context.WithCancel(context.Background())
+		fn := server([]string{fmt.Sprintf(info, true, 10)}, []string{"[" + fmt.Sprintf(shard1, 10) + "]"})
+		return tst{
+			fn: func(req *http.Request) (*http.Response, error) {
+				if req.URL.Path == "/_active_tasks" {
+					cancel()
+				}
+				return fn(req)
+			},
+			ctx: ctx,
+			progress: []IndexProgress{
+				{DDoc: "_design/app", Tasks: 1, ChangesDone: 10, TotalChanges: 100, Progress: 10},
+			},
+			status: http.StatusInternalServerError,
+			err:    "context canceled",
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		ctx := test.ctx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		var progress []IndexProgress
+		err := WarmView(ctx, newTestKivikDB(t, test.fn), "app", "by-name", func(p IndexProgress) {
+			progress = append(progress, p)
+		})
+		if d := testy.DiffInterface(test.progress, progress); d != nil {
+			t.Errorf("Unexpected progress:\n%s", d)
+		}
+		testy.StatusErrorRE(t, test.err, test.status, err)
+	})
+}
+
+func TestWarmViewEscapedDBName(t *testing.T) {
+	defer func(d time.Duration) { indexPollInterval = d }(indexPollInterval)
+	indexPollInterval = time.Millisecond
+
+	var infoPolls int
+	db := newTestKivikClient(t, func(req *http.Request) (*http.Response, error) {
+		var body string
+		switch req.URL.EscapedPath() {
+		case "/tenantA%2Fdata":
+			body = `{"db_name":"tenantA/data","update_seq":"100-xxx"}`
+		case "/tenantA%2Fdata/_design/app/_view/by-name":
+			body = `{"total_rows":0,"offset":0,"rows":[]}`
+		case "/tenantA%2Fdata/_design/app/_info":
+			infoPolls++
+			body = fmt.Sprintf(`{"name":"app","view_index":{"updater_running":%t,"update_seq":%d}}`, infoPolls == 1, infoPolls*50)
+		case "/_active_tasks":
+			body = `[{"type":"indexer","database":"shards/00000000-ffffffff/tenantA/data.1565","design_document":"_design/app","changes_done":25,"total_changes":100}]`
+		default:
+			return nil, fmt.Errorf("unexpected request: %s", req.URL.EscapedPath())
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       Body(body),
+		}, nil
+	}).DB("tenantA/data")
+	var progress []IndexProgress
+	err := WarmView(context.Background(), db, "app", "by-name", func(p IndexProgress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []IndexProgress{
+		{DDoc: "_design/app", Tasks: 1, ChangesDone: 25, TotalChanges: 100, Progress: 25},
+		{DDoc: "_design/app", Progress: 100},
+	}
+	if d := testy.DiffInterface(expected, progress); d != nil {
+		t.Errorf("Unexpected progress:\n%s", d)
+	}
+}
+
+func TestQueryIndexProgressInvalid(t *testing.T) {
+	db := newTestDB(nil, errors.New("unexpected request"))
+	_, err := db.Query(context.Background(), "app", "by-name", map[string]interface{}{
+		OptionIndexProgress: "foo",
+	})
+	testy.StatusError(t, "kivik: option 'kivik:index-progress' must be a non-nil func(couchdb.IndexProgress), not string", http.StatusBadRequest, err)
+}
+
+func TestTaskDBName(t *testing.T) {
+	tests := map[string]string{
+		"testdb":                                     "testdb",
+		"shards/00000000-7fffffff/testdb.1565":       "testdb",
+		"shards/00000000-7fffffff/a/b.c.1565":        "a/b.c",
+		"shards/00000000-7fffffff/_users.1565353451": "_users",
+	}
+	for input, want := range tests {
+		if got := taskDBName(input); got != want {
+			t.Errorf("taskDBName(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
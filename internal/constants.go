@@ -15,12 +15,25 @@ package internal
 // Common constants, placed here to allow importing in chttp and root package
 // without import cycles.
 const (
-	OptionUserAgent            = "User-Agent"
-	OptionHTTPClient           = "kivik:httpClient"
-	OptionFullCommit           = "X-Couch-Full-Commit"
-	OptionIfNoneMatch          = "If-None-Match"
-	OptionPartition            = "kivik:partition"
-	OptionNoMultipartPut       = "kivik:no-multipart-put"
-	OptionNoMultipartGet       = "kivik:no-multipart-get"
-	OptionNoCompressedRequests = "kivik:no-compressed-requests"
+	OptionUserAgent             = "User-Agent"
+	OptionHTTPClient            = "kivik:httpClient"
+	OptionFullCommit            = "X-Couch-Full-Commit"
+	OptionIfNoneMatch           = "If-None-Match"
+	OptionPartition             = "kivik:partition"
+	OptionNoMultipartPut        = "kivik:no-multipart-put"
+	OptionNoMultipartGet        = "kivik:no-multipart-get"
+	OptionMultipartBulkGet      = "kivik:multipart-bulk-get"
+	OptionRangeOffset           = "kivik:range-offset"
+	OptionRangeLength           = "kivik:range-length"
+	OptionVerifyDigest          = "kivik:verify-digest"
+	OptionRawAttachmentEncoding = "kivik:raw-attachment-encoding"
+	OptionCompressedAttachments = "kivik:compressed-attachments"
+	OptionFindPageSize          = "kivik:find-page-size"
+	OptionPageSize              = "kivik:page-size"
+	OptionExecutionStats        = "kivik:execution-stats"
+	OptionIndexProgress         = "kivik:index-progress"
+	OptionTransientReplication  = "kivik:transient-replication"
+	OptionReplicatorDB          = "kivik:replicator-db"
+	OptionSchedulerJobs         = "kivik:scheduler-jobs"
+	OptionNoCompressedRequests  = "kivik:no-compressed-requests"
 )
@@ -0,0 +1,296 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+// Package mango provides a typed builder for CouchDB Mango queries, for use
+// with the CouchDB driver's Find and Explain.
+package mango
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Query is a Mango query, which may be passed to
+// [github.com/go-kivik/kivik/v4.DB.Find] or
+// [github.com/go-kivik/kivik/v4.DB.Explain] in place of a map or raw JSON.
+// The CouchDB driver validates the query before it is sent to the server.
+//
+// Example:
+//
+//	query := &mango.Query{
+//	    Selector: mango.And(
+//	        mango.Eq("type", "user"),
+//	        mango.Gte("age", 21),
+//	    ),
+//	    Fields: []string{"_id", "name"},
+//	    Sort:   []mango.SortField{{Field: "age"}},
+//	    Limit:  50,
+//	}
+//	rows := db.Find(ctx, query)
+type Query struct {
+	// Selector selects the documents to return. It is required.
+	Selector Selector
+
+	// Fields limits the fields returned for each document.
+	Fields []string
+
+	// Sort orders the results. All fields must be sorted in the same
+	// direction.
+	Sort []SortField
+
+	// Limit is the maximum number of results returned, if positive.
+	Limit int64
+
+	// Skip is the number of results to skip.
+	Skip int64
+
+	// UseIndex names the index to use, as either a design document name, or
+	// a design document name and index name.
+	UseIndex []string
+
+	// Bookmark resumes a previous query, using the value returned by
+	// [github.com/go-kivik/kivik/v4.ResultSet.Bookmark].
+	Bookmark string
+
+	// ExecutionStats requests statistics about the execution of the query.
+	ExecutionStats bool
+}
+
+var _ json.Marshaler = &Query{}
+
+// Validate returns an error if the query is incomplete, or invalid.
+func (q Query) Validate() error {
+	if err := q.Selector.validate(false); err != nil {
+		return err
+	}
+	for _, field := range q.Fields {
+		if field == "" {
+			return queryError("fields must not be empty")
+		}
+	}
+	sorted := make(map[string]bool, len(q.Sort))
+	for _, sort := range q.Sort {
+		if sort.Field == "" {
+			return queryError("sort field required")
+		}
+		if sort.Descending != q.Sort[0].Descending {
+			return queryError("all sort fields must use the same direction")
+		}
+		if sorted[sort.Field] {
+			return queryError(fmt.Sprintf("duplicate sort field %q", sort.Field))
+		}
+		sorted[sort.Field] = true
+	}
+	if q.Limit < 0 {
+		return queryError("limit must not be negative")
+	}
+	if q.Skip < 0 {
+		return queryError("skip must not be negative")
+	}
+	switch len(q.UseIndex) {
+	case 0, 1, 2: // nolint:gomnd
+	default:
+		return queryError("use_index must name a design document, and optionally an index")
+	}
+	for _, name := range q.UseIndex {
+		if name == "" {
+			return queryError("use_index must not be empty")
+		}
+	}
+	return nil
+}
+
+// MarshalJSON validates the query, and encodes it as JSON.
+func (q Query) MarshalJSON() ([]byte, error) {
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+	var useIndex interface{}
+	switch len(q.UseIndex) {
+	case 1:
+		useIndex = q.UseIndex[0]
+	case 2: // nolint:gomnd
+		useIndex = q.UseIndex
+	}
+	return json.Marshal(struct {
+		Selector       Selector    `json:"selector"`
+		Fields         []string    `json:"fields,omitempty"`
+		Sort           []SortField `json:"sort,omitempty"`
+		Limit          int64       `json:"limit,omitempty"`
+		Skip           int64       `json:"skip,omitempty"`
+		UseIndex       interface{} `json:"use_index,omitempty"`
+		Bookmark       string      `json:"bookmark,omitempty"`
+		ExecutionStats bool        `json:"execution_stats,omitempty"`
+	}{
+		Selector:       q.Selector,
+		Fields:         q.Fields,
+		Sort:           q.Sort,
+		Limit:          q.Limit,
+		Skip:           q.Skip,
+		UseIndex:       useIndex,
+		Bookmark:       q.Bookmark,
+		ExecutionStats: q.ExecutionStats,
+	})
+}
+
+// SortField is a field by which the results of a Query are sorted.
+type SortField struct {
+	Field      string
+	Descending bool
+}
+
+// MarshalJSON encodes the sort field as JSON.
+func (s SortField) MarshalJSON() ([]byte, error) {
+	dir := "asc"
+	if s.Descending {
+		dir = "desc"
+	}
+	return json.Marshal(map[string]string{s.Field: dir})
+}
+
+// Selector is a Mango selector expression. Selectors are built with the
+// functions in this package, such as Eq, And and ElemMatch. The zero value is
+// not a valid selector.
+type Selector struct {
+	// op is the Mango operator, such as "$eq" or "$and".
+	op    string
+	field string
+	value interface{}
+	// children are the operands of combination operators, and of $elemMatch.
+	children []Selector
+}
+
+var _ json.Marshaler = Selector{}
+
+func fieldSelector(op, field string, value interface{}) Selector {
+	return Selector{op: op, field: field, value: value}
+}
+
+// Eq selects documents where field equals value.
+func Eq(field string, value interface{}) Selector { return fieldSelector("$eq", field, value) }
+
+// Ne selects documents where field does not equal value.
+func Ne(field string, value interface{}) Selector { return fieldSelector("$ne", field, value) }
+
+// Gt selects documents where field is greater than value.
+func Gt(field string, value interface{}) Selector { return fieldSelector("$gt", field, value) }
+
+// Gte selects documents where field is greater than, or equal to, value.
+func Gte(field string, value interface{}) Selector { return fieldSelector("$gte", field, value) }
+
+// Lt selects documents where field is less than value.
+func Lt(field string, value interface{}) Selector { return fieldSelector("$lt", field, value) }
+
+// Lte selects documents where field is less than, or equal to, value.
+func Lte(field string, value interface{}) Selector { return fieldSelector("$lte", field, value) }
+
+// In selects documents where field equals any of values.
+func In(field string, values ...interface{}) Selector {
+	return fieldSelector("$in", field, values)
+}
+
+// Nin selects documents where field equals none of values.
+func Nin(field string, values ...interface{}) Selector {
+	return fieldSelector("$nin", field, values)
+}
+
+// Exists selects documents where field exists, or does not exist.
+func Exists(field string, exists bool) Selector {
+	return fieldSelector("$exists", field, exists)
+}
+
+// Regex selects documents where field is a string matching pattern. The
+// pattern is evaluated by the server, using Erlang regular expression syntax.
+func Regex(field, pattern string) Selector {
+	return fieldSelector("$regex", field, pattern)
+}
+
+// ElemMatch selects documents where field is an array, with at least one
+// element matching sel. Fields within sel are relative to the array element,
+// and may be empty to match the element itself, as in Eq("", "red").
+func ElemMatch(field string, sel Selector) Selector {
+	return Selector{op: "$elemMatch", field: field, children: []Selector{sel}}
+}
+
+// And selects documents matching all of sels.
+func And(sels ...Selector) Selector {
+	return Selector{op: "$and", children: sels}
+}
+
+// Or selects documents matching any of sels.
+func Or(sels ...Selector) Selector {
+	return Selector{op: "$or", children: sels}
+}
+
+// Nor selects documents matching none of sels.
+func Nor(sels ...Selector) Selector {
+	return Selector{op: "$nor", children: sels}
+}
+
+// Not selects documents not matching sel.
+func Not(sel Selector) Selector {
+	return Selector{op: "$not", children: []Selector{sel}}
+}
+
+// validate checks the selector. Within $elemMatch, elem is true, and fields
+// may be empty.
+func (s Selector) validate(elem bool) error {
+	switch s.op {
+	case "":
+		return queryError("selector required")
+	case "$and", "$or", "$nor":
+		if len(s.children) == 0 {
+			return queryError(fmt.Sprintf("%s requires at least one selector", s.op))
+		}
+	case "$not":
+	default:
+		if s.field == "" && !elem {
+			return queryError(fmt.Sprintf("%s: field required", s.op))
+		}
+		if s.op == "$regex" && s.value == "" {
+			return queryError(fmt.Sprintf("%s: regular expression required", s.field))
+		}
+	}
+	for _, child := range s.children {
+		if err := child.validate(elem || s.op == "$elemMatch"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes the selector as JSON.
+func (s Selector) MarshalJSON() ([]byte, error) {
+	if err := s.validate(true); err != nil {
+		return nil, err
+	}
+	switch s.op {
+	case "$and", "$or", "$nor":
+		return json.Marshal(map[string]interface{}{s.op: s.children})
+	case "$not":
+		return json.Marshal(map[string]interface{}{s.op: s.children[0]})
+	case "$elemMatch":
+		return json.Marshal(map[string]interface{}{s.field: map[string]interface{}{s.op: s.children[0]}})
+	}
+	if s.field == "" {
+		return json.Marshal(map[string]interface{}{s.op: s.value})
+	}
+	return json.Marshal(map[string]interface{}{s.field: map[string]interface{}{s.op: s.value}})
+}
+
+func queryError(msg string) error {
+	return &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: invalid query: " + msg)}
+}
@@ -0,0 +1,141 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package mango
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestQueryMarshalJSON(t *testing.T) {
+	type tst struct {
+		query    *Query
+		expected string
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("simple selector", tst{
+		query:    &Query{Selector: Eq("type", "user")},
+		expected: `{"selector":{"type":{"$eq":"user"}}}`,
+	})
+	tests.Add("all options", tst{
+		query: &Query{
+			Selector: And(
+				Gte("age", 21),
+				Lt("age", 65),
+				Or(Regex("name", "^A"), In("role", "admin", "owner")),
+				Not(Exists("deleted", true)),
+			),
+			Fields:         []string{"_id", "name"},
+			Sort:           []SortField{{Field: "age", Descending: true}, {Field: "name", Descending: true}},
+			Limit:          10,
+			Skip:           5,
+			UseIndex:       []string{"ddoc", "by-age"},
+			Bookmark:       "xyz",
+			ExecutionStats: true,
+		},
+		expected: `{
+			"selector": {"$and": [
+				{"age": {"$gte": 21}},
+				{"age": {"$lt": 65}},
+				{"$or": [{"name": {"$regex": "^A"}}, {"role": {"$in": ["admin", "owner"]}}]},
+				{"$not": {"deleted": {"$exists": true}}}
+			]},
+			"fields": ["_id", "name"],
+			"sort": [{"age": "desc"}, {"name": "desc"}],
+			"limit": 10,
+			"skip": 5,
+			"use_index": ["ddoc", "by-age"],
+			"bookmark": "xyz",
+			"execution_stats": true
+		}`,
+	})
+	tests.Add("elemMatch", tst{
+		query: &Query{
+			Selector: Nor(
+				ElemMatch("tags", Eq("", "red")),
+				ElemMatch("items", And(Ne("sku", "x"), Nin("qty", 0))),
+			),
+			UseIndex: []string{"ddoc"},
+		},
+		expected: `{
+			"selector": {"$nor": [
+				{"tags": {"$elemMatch": {"$eq": "red"}}},
+				{"items": {"$elemMatch": {"$and": [{"sku": {"$ne": "x"}}, {"qty": {"$nin": [0]}}]}}}
+			]},
+			"use_index": "ddoc"
+		}`,
+	})
+	tests.Add("missing selector", tst{
+		query:  &Query{},
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid query: selector required",
+	})
+	tests.Add("missing field", tst{
+		query:  &Query{Selector: Gt("", 1)},
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid query: $gt: field required",
+	})
+	tests.Add("empty combination", tst{
+		query:  &Query{Selector: And(Eq("a", 1), Or())},
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid query: $or requires at least one selector",
+	})
+	tests.Add("empty regex", tst{
+		query:  &Query{Selector: Regex("name", "")},
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid query: name: regular expression required",
+	})
+	tests.Add("mixed sort directions", tst{
+		query: &Query{
+			Selector: Eq("a", 1),
+			Sort:     []SortField{{Field: "a"}, {Field: "b", Descending: true}},
+		},
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid query: all sort fields must use the same direction",
+	})
+	tests.Add("duplicate sort field", tst{
+		query: &Query{
+			Selector: Eq("a", 1),
+			Sort:     []SortField{{Field: "a"}, {Field: "b"}, {Field: "a"}},
+		},
+		status: http.StatusBadRequest,
+		err:    `kivik: invalid query: duplicate sort field "a"`,
+	})
+	tests.Add("negative limit", tst{
+		query:  &Query{Selector: Eq("a", 1), Limit: -1},
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid query: limit must not be negative",
+	})
+	tests.Add("invalid use_index", tst{
+		query:  &Query{Selector: Eq("a", 1), UseIndex: []string{"a", "b", "c"}},
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid query: use_index must name a design document, and optionally an index",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		err := test.query.Validate()
+		testy.StatusError(t, test.err, test.status, err)
+		result, err := json.Marshal(test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d := testy.DiffJSON([]byte(test.expected), result); d != nil {
+			t.Error(d)
+		}
+	})
+}
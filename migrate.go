@@ -0,0 +1,467 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// Schema is the desired set of Mango indexes and design documents of a
+// database.
+type Schema struct {
+	Indexes    []SchemaIndex
+	DesignDocs []SchemaDesignDoc
+}
+
+// SchemaIndex is a Mango index of a Schema.
+type SchemaIndex struct {
+	// DDoc and Name identify the index. Both are required, so that the index
+	// can be found again.
+	DDoc, Name string
+
+	// Index is the index definition, in the form accepted by
+	// [github.com/go-kivik/kivik/v4.DB.CreateIndex].
+	Index interface{}
+}
+
+// SchemaDesignDoc is a design document of a Schema.
+type SchemaDesignDoc struct {
+	// ID is the design document ID, with or without the _design/ prefix.
+	ID string
+
+	// Doc is the content of the design document. Any _id or _rev fields are
+	// ignored.
+	Doc interface{}
+}
+
+// MigrationAction is the action taken by a MigrationStep.
+type MigrationAction string
+
+// Migration actions.
+const (
+	MigrationCreate MigrationAction = "create"
+	MigrationUpdate MigrationAction = "update"
+	MigrationDelete MigrationAction = "delete"
+)
+
+// MigrationStep is a single change of a MigrationPlan.
+type MigrationStep struct {
+	Action MigrationAction
+
+	// DDoc is the design document ID, with the _design/ prefix.
+	DDoc string
+
+	// Name is the index name, for index steps. It is empty for design
+	// document steps.
+	Name string
+
+	// Index is the desired index definition, for index create and update
+	// steps.
+	Index interface{}
+
+	// Doc is the desired design document, for design document create and
+	// update steps.
+	Doc map[string]interface{}
+}
+
+// MigrationPlan is the set of changes needed to bring a database in line with
+// a Schema.
+type MigrationPlan struct {
+	Steps []MigrationStep
+}
+
+// Empty returns true if the plan has no steps.
+func (p *MigrationPlan) Empty() bool {
+	return len(p.Steps) == 0
+}
+
+// Migrator reconciles the Mango indexes and design documents of a database
+// with a Schema.
+//
+// Example:
+//
+//	m := &couchdb.Migrator{DB: client.DB("users"), Prune: true, Stage: true}
+//	plan, err := m.Migrate(ctx, &couchdb.Schema{
+//	    Indexes: []couchdb.SchemaIndex{
+//	        {DDoc: "indexes", Name: "by-type", Index: map[string]interface{}{
+//	            "fields": []string{"type"},
+//	        }},
+//	    },
+//	})
+type Migrator struct {
+	// DB is the database to migrate.
+	DB *kivik.DB
+
+	// Prune, if true, deletes indexes and design documents which are not part
+	// of the schema. Design documents containing Mango indexes are managed
+	// only through their indexes.
+	Prune bool
+
+	// Stage, if true, first stores new and updated design documents with
+	// views under a temporary ID, and waits for their views to build, before
+	// swapping them into place. CouchDB shares view indexes between design
+	// documents with identical views, so queries against the old views are not
+	// blocked while the new indexes build.
+	Stage bool
+}
+
+// stagedSuffix is appended to the ID of a design document while it is staged.
+const stagedSuffix = "-staged"
+
+// Migrate plans and applies the changes needed to bring the database in line
+// with schema. Migrate is idempotent. The applied plan is returned.
+func (m *Migrator) Migrate(ctx context.Context, schema *Schema) (*MigrationPlan, error) {
+	plan, err := m.Plan(ctx, schema)
+	if err != nil {
+		return nil, err
+	}
+	return plan, m.Apply(ctx, plan)
+}
+
+// Plan compares schema with the database, and returns the changes needed to
+// bring the database in line with the schema. Deletions are planned only when
+// Prune is set.
+func (m *Migrator) Plan(ctx context.Context, schema *Schema) (*MigrationPlan, error) {
+	plan := &MigrationPlan{}
+	if err := m.planIndexes(ctx, schema.Indexes, plan); err != nil {
+		return nil, err
+	}
+	if err := m.planDesignDocs(ctx, schema.DesignDocs, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+func (m *Migrator) planIndexes(ctx context.Context, indexes []SchemaIndex, plan *MigrationPlan) error {
+	current, err := m.currentIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	desired := make(map[string]bool, len(indexes))
+	for _, index := range indexes {
+		if index.DDoc == "" {
+			return missingArg("ddoc")
+		}
+		if index.Name == "" {
+			return missingArg("name")
+		}
+		step := MigrationStep{DDoc: designDocID(index.DDoc), Name: index.Name, Index: index.Index}
+		key := step.DDoc + "/" + step.Name
+		desired[key] = true
+		def, ok := current[key]
+		switch {
+		case !ok:
+			step.Action = MigrationCreate
+		case !sameIndex(def, index.Index):
+			step.Action = MigrationUpdate
+		default:
+			continue
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	if !m.Prune {
+		return nil
+	}
+	for _, key := range sortedKeys(current) {
+		if desired[key] {
+			continue
+		}
+		ddoc, name := splitIndexKey(key)
+		plan.Steps = append(plan.Steps, MigrationStep{Action: MigrationDelete, DDoc: ddoc, Name: name})
+	}
+	return nil
+}
+
+func (m *Migrator) planDesignDocs(ctx context.Context, ddocs []SchemaDesignDoc, plan *MigrationPlan) error {
+	current, err := m.currentDesignDocs(ctx)
+	if err != nil {
+		return err
+	}
+	desired := make(map[string]bool, len(ddocs))
+	for _, ddoc := range ddocs {
+		if ddoc.ID == "" {
+			return missingArg("id")
+		}
+		doc, err := normalizeDesignDoc(ddoc.Doc)
+		if err != nil {
+			return err
+		}
+		step := MigrationStep{DDoc: designDocID(ddoc.ID), Doc: doc}
+		desired[step.DDoc] = true
+		existing, ok := current[step.DDoc]
+		switch {
+		case !ok:
+			step.Action = MigrationCreate
+		case !reflect.DeepEqual(existing, doc):
+			step.Action = MigrationUpdate
+		default:
+			continue
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	if !m.Prune {
+		return nil
+	}
+	ids := make([]string, 0, len(current))
+	for id := range current {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if desired[id] || current[id]["language"] == "query" || strings.HasSuffix(id, stagedSuffix) {
+			continue
+		}
+		plan.Steps = append(plan.Steps, MigrationStep{Action: MigrationDelete, DDoc: id})
+	}
+	return nil
+}
+
+// Apply applies plan to the database. Each step is checked against the
+// current state of the database before it is applied, so a plan may safely be
+// applied more than once.
+func (m *Migrator) Apply(ctx context.Context, plan *MigrationPlan) error {
+	var indexes map[string]interface{}
+	for _, step := range plan.Steps {
+		if step.Name == "" {
+			if err := m.applyDesignDoc(ctx, step); err != nil {
+				return err
+			}
+			continue
+		}
+		if indexes == nil {
+			var err error
+			if indexes, err = m.currentIndexes(ctx); err != nil {
+				return err
+			}
+		}
+		if err := m.applyIndex(ctx, step, indexes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) applyIndex(ctx context.Context, step MigrationStep, current map[string]interface{}) error {
+	def, exists := current[step.DDoc+"/"+step.Name]
+	if step.Action != MigrationDelete && exists && sameIndex(def, step.Index) {
+		return nil
+	}
+	if exists {
+		if err := m.DB.DeleteIndex(ctx, step.DDoc, step.Name); err != nil && kivik.HTTPStatus(err) != http.StatusNotFound {
+			return err
+		}
+	}
+	if step.Action == MigrationDelete {
+		return nil
+	}
+	return m.DB.CreateIndex(ctx, step.DDoc, step.Name, step.Index)
+}
+
+func (m *Migrator) applyDesignDoc(ctx context.Context, step MigrationStep) error {
+	if step.Action == MigrationDelete {
+		return m.deleteDesignDoc(ctx, step.DDoc)
+	}
+	if !m.Stage || step.Doc["views"] == nil {
+		return m.putDesignDoc(ctx, step.DDoc, step.Doc)
+	}
+	stagedID := step.DDoc + stagedSuffix
+	if err := m.stageDesignDoc(ctx, stagedID, step.Doc); err != nil {
+		return err
+	}
+	if err := m.putDesignDoc(ctx, step.DDoc, step.Doc); err != nil {
+		return err
+	}
+	return m.deleteDesignDoc(ctx, stagedID)
+}
+
+// stageDesignDoc stores doc as stagedID, and waits for its views to build, so
+// that storing it under its real ID reuses the built indexes.
+func (m *Migrator) stageDesignDoc(ctx context.Context, stagedID string, doc map[string]interface{}) error {
+	if err := m.putDesignDoc(ctx, stagedID, doc); err != nil {
+		return err
+	}
+	views, _ := doc["views"].(map[string]interface{})
+	for _, view := range sortedKeys(views) {
+		rows := m.DB.Query(ctx, stagedID, view, kivik.Options{"limit": 0})
+		err := rows.Err()
+		_ = rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putDesignDoc stores doc as id, unless it is already current.
+func (m *Migrator) putDesignDoc(ctx context.Context, id string, doc map[string]interface{}) error {
+	existing, rev, err := m.getDesignDoc(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing != nil && reflect.DeepEqual(existing, doc) {
+		return nil
+	}
+	body := make(map[string]interface{}, len(doc)+1)
+	for k, v := range doc {
+		body[k] = v
+	}
+	if rev != "" {
+		body["_rev"] = rev
+	}
+	_, err = m.DB.Put(ctx, id, body)
+	return err
+}
+
+func (m *Migrator) deleteDesignDoc(ctx context.Context, id string) error {
+	rev, err := m.DB.GetRev(ctx, id)
+	if kivik.HTTPStatus(err) == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = m.DB.Delete(ctx, id, rev)
+	return err
+}
+
+// getDesignDoc returns the normalized content and rev of the design document
+// id, or a nil document if it does not exist.
+func (m *Migrator) getDesignDoc(ctx context.Context, id string) (map[string]interface{}, string, error) {
+	var doc map[string]interface{}
+	err := m.DB.Get(ctx, id).ScanDoc(&doc)
+	if kivik.HTTPStatus(err) == http.StatusNotFound {
+		return nil, "", nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	rev, _ := doc["_rev"].(string)
+	delete(doc, "_id")
+	delete(doc, "_rev")
+	return doc, rev, nil
+}
+
+// currentIndexes returns the definitions of the Mango indexes in the
+// database, keyed by design document ID and index name.
+func (m *Migrator) currentIndexes(ctx context.Context) (map[string]interface{}, error) {
+	indexes, err := m.DB.GetIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{}, len(indexes))
+	for _, index := range indexes {
+		if index.Type == "special" {
+			continue
+		}
+		result[designDocID(index.DesignDoc)+"/"+index.Name] = index.Definition
+	}
+	return result, nil
+}
+
+// currentDesignDocs returns the normalized content of the design documents in
+// the database, keyed by ID.
+func (m *Migrator) currentDesignDocs(ctx context.Context) (map[string]map[string]interface{}, error) {
+	rows := m.DB.DesignDocs(ctx, kivik.Options{"include_docs": true})
+	defer rows.Close() // nolint:errcheck
+	result := map[string]map[string]interface{}{}
+	for rows.Next() {
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			return nil, err
+		}
+		id, _ := doc["_id"].(string)
+		delete(doc, "_id")
+		delete(doc, "_rev")
+		result[id] = doc
+	}
+	return result, rows.Err()
+}
+
+func designDocID(id string) string {
+	if strings.HasPrefix(id, "_design/") {
+		return id
+	}
+	return "_design/" + id
+}
+
+func splitIndexKey(key string) (ddoc, name string) {
+	i := strings.LastIndex(key, "/")
+	return key[:i], key[i+1:]
+}
+
+// normalizeDesignDoc converts doc to its JSON object representation, without
+// _id and _rev fields, for comparison with stored design documents.
+func normalizeDesignDoc(doc interface{}) (map[string]interface{}, error) {
+	var result map[string]interface{}
+	if err := normalizeJSON(doc, &result); err != nil {
+		return nil, err
+	}
+	delete(result, "_id")
+	delete(result, "_rev")
+	return result, nil
+}
+
+// sameIndex compares a stored index definition with a desired one. Fields
+// given as bare names are sorted in ascending order, and an empty partial
+// filter selector is the same as none.
+func sameIndex(current, desired interface{}) bool {
+	var a, b map[string]interface{}
+	if normalizeJSON(current, &a) != nil || normalizeJSON(desired, &b) != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalizeIndex(a), normalizeIndex(b))
+}
+
+func normalizeIndex(def map[string]interface{}) map[string]interface{} {
+	if sel, ok := def["partial_filter_selector"].(map[string]interface{}); ok && len(sel) == 0 {
+		delete(def, "partial_filter_selector")
+	}
+	if fields, ok := def["fields"].([]interface{}); ok {
+		for i, field := range fields {
+			if name, ok := field.(string); ok {
+				fields[i] = map[string]interface{}{name: "asc"}
+			}
+		}
+	}
+	return def
+}
+
+// normalizeJSON converts in to the JSON representation out. in may be JSON
+// data, as accepted by CreateIndex, or any value which marshals to JSON.
+func normalizeJSON(in, out interface{}) error {
+	in, err := deJSONify(in)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	return json.Unmarshal(body, out)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,181 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func sortedDDocIDs(ddocs map[string]map[string]interface{}) []string {
+	m := make(map[string]interface{}, len(ddocs))
+	for id := range ddocs {
+		m[id] = nil
+	}
+	return sortedKeys(m)
+}
+
+func TestMigratorMigrate(t *testing.T) {
+	views := map[string]interface{}{
+		"by-name": map[string]interface{}{"map": "function(doc) { emit(doc.name); }"},
+	}
+	type tst struct {
+		indexes  map[string]interface{}
+		ddocs    map[string]map[string]interface{}
+		migrator Migrator
+		schema   *Schema
+		steps    []MigrationStep
+		requests []string
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("missing index name", tst{
+		schema: &Schema{Indexes: []SchemaIndex{{DDoc: "idx"}}},
+		status: http.StatusBadRequest,
+		err:    "kivik: name required",
+	})
+	tests.Add("up to date", tst{
+		indexes: map[string]interface{}{
+			"_design/idx/by-type": map[string]interface{}{"fields": []interface{}{map[string]interface{}{"type": "asc"}}, "partial_filter_selector": map[string]interface{}{}},
+		},
+		ddocs: map[string]map[string]interface{}{
+			"_design/app": {"_id": "_design/app", "_rev": "1-a", "views": views},
+		},
+		schema: &Schema{
+			Indexes:    []SchemaIndex{{DDoc: "idx", Name: "by-type", Index: map[string]interface{}{"fields": []string{"type"}}}},
+			DesignDocs: []SchemaDesignDoc{{ID: "app", Doc: map[string]interface{}{"views": views}}},
+		},
+	})
+	tests.Add("create", tst{
+		schema: &Schema{
+			Indexes:    []SchemaIndex{{DDoc: "idx", Name: "by-type", Index: `{"fields":["type"]}`}},
+			DesignDocs: []SchemaDesignDoc{{ID: "_design/app", Doc: map[string]interface{}{"views": views}}},
+		},
+		steps: []MigrationStep{
+			{Action: MigrationCreate, DDoc: "_design/idx", Name: "by-type", Index: `{"fields":["type"]}`},
+			{Action: MigrationCreate, DDoc: "_design/app", Doc: map[string]interface{}{"views": views}},
+		},
+		requests: []string{
+			"POST _index",
+			"PUT _design/app",
+		},
+	})
+	tests.Add("update and prune", tst{
+		indexes: map[string]interface{}{
+			"_design/idx/by-type": map[string]interface{}{"fields": []interface{}{map[string]interface{}{"type": "asc"}}},
+			"_design/idx/old":     map[string]interface{}{"fields": []interface{}{map[string]interface{}{"old": "asc"}}},
+		},
+		ddocs: map[string]map[string]interface{}{
+			"_design/app": {"_id": "_design/app", "_rev": "1-a", "views": map[string]interface{}{}},
+			"_design/old": {"_id": "_design/old", "_rev": "1-a"},
+			"_design/idx": {"_id": "_design/idx", "_rev": "1-a", "language": "query"},
+		},
+		migrator: Migrator{Prune: true},
+		schema: &Schema{
+			Indexes:    []SchemaIndex{{DDoc: "idx", Name: "by-type", Index: map[string]interface{}{"fields": []string{"type", "age"}}}},
+			DesignDocs: []SchemaDesignDoc{{ID: "app", Doc: map[string]interface{}{"_id": "_design/app", "views": views}}},
+		},
+		steps: []MigrationStep{
+			{Action: MigrationUpdate, DDoc: "_design/idx", Name: "by-type", Index: map[string]interface{}{"fields": []string{"type", "age"}}},
+			{Action: MigrationDelete, DDoc: "_design/idx", Name: "old"},
+			{Action: MigrationUpdate, DDoc: "_design/app", Doc: map[string]interface{}{"views": views}},
+			{Action: MigrationDelete, DDoc: "_design/old"},
+		},
+		requests: []string{
+			"DELETE _index/_design/idx/json/by-type",
+			"POST _index",
+			"DELETE _index/_design/idx/json/old",
+			"PUT _design/app",
+			"DELETE _design/old",
+		},
+	})
+	tests.Add("staged", tst{
+		ddocs: map[string]map[string]interface{}{
+			"_design/app": {"_id": "_design/app", "_rev": "1-a", "views": map[string]interface{}{}},
+		},
+		migrator: Migrator{Stage: true},
+		schema: &Schema{
+			DesignDocs: []SchemaDesignDoc{{ID: "app", Doc: map[string]interface{}{"views": views}}},
+		},
+		steps: []MigrationStep{
+			{Action: MigrationUpdate, DDoc: "_design/app", Doc: map[string]interface{}{"views": views}},
+		},
+		requests: []string{
+			"PUT _design/app-staged",
+			"PUT _design/app",
+			"DELETE _design/app-staged",
+		},
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		s := &fakeDB{indexes: test.indexes}
+		for _, id := range sortedDDocIDs(test.ddocs) {
+			s.put(test.ddocs[id])
+		}
+		m := test.migrator
+		m.DB = newTestKivikDB(t, (&fakeCouch{dbs: map[string]*fakeDB{"testdb": s}}).handle)
+		plan, err := m.Migrate(context.Background(), test.schema)
+		testy.StatusError(t, test.err, test.status, err)
+		if d := testy.DiffAsJSON(test.steps, plan.Steps); d != nil {
+			t.Errorf("Unexpected plan:\n%s", d)
+		}
+		if d := testy.DiffInterface(test.requests, s.requests); d != nil {
+			t.Errorf("Unexpected requests:\n%s", d)
+		}
+		// A second migration must find nothing to do.
+		s.requests = nil
+		plan, err = m.Migrate(context.Background(), test.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !plan.Empty() {
+			t.Errorf("Expected empty plan, got %v", plan.Steps)
+		}
+		if len(s.requests) != 0 {
+			t.Errorf("Unexpected requests on second migration: %v", s.requests)
+		}
+	})
+}
+
+func TestMigratorApplyIdempotent(t *testing.T) {
+	s := &fakeDB{}
+	s.put(map[string]interface{}{"_id": "_design/old", "_rev": "1-a"})
+	m := &Migrator{DB: newTestKivikDB(t, (&fakeCouch{dbs: map[string]*fakeDB{"testdb": s}}).handle)}
+	plan := &MigrationPlan{Steps: []MigrationStep{
+		{Action: MigrationCreate, DDoc: "_design/idx", Name: "by-type", Index: map[string]interface{}{"fields": []string{"type"}}},
+		{Action: MigrationCreate, DDoc: "_design/app", Doc: map[string]interface{}{"language": "javascript"}},
+		{Action: MigrationDelete, DDoc: "_design/old"},
+	}}
+	for i := 0; i < 2; i++ {
+		if err := m.Apply(context.Background(), plan); err != nil {
+			t.Fatalf("Apply #%d failed: %s", i+1, err)
+		}
+	}
+	want := []string{"POST _index", "PUT _design/app", "DELETE _design/old"}
+	if d := testy.DiffInterface(want, s.requests); d != nil {
+		t.Error(d)
+	}
+}
+
+func TestMigratorPlanError(t *testing.T) {
+	m := &Migrator{DB: newTestKivikDB(t, func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("index failure")
+	})}
+	_, err := m.Plan(context.Background(), &Schema{})
+	testy.ErrorRE(t, "index failure", err)
+}
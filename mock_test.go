@@ -13,10 +13,16 @@
 package couchdb
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
+	"encoding/json"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -25,6 +31,7 @@ import (
 
 	"github.com/go-kivik/couchdb/v4/chttp"
 	"github.com/go-kivik/couchdb/v4/internal"
+	kivik "github.com/go-kivik/kivik/v4"
 	"github.com/go-kivik/kivik/v4/kiviktest/kt"
 )
 
@@ -74,6 +81,27 @@ func newCustomClient(fn func(*http.Request) (*http.Response, error)) *client {
 	}
 }
 
+// newTestKivikClient returns a kivik.Client, backed by this driver, which sends
+// all requests to fn.
+func newTestKivikClient(t *testing.T, fn func(*http.Request) (*http.Response, error)) *kivik.Client {
+	t.Helper()
+	client, err := kivik.New("couch", "http://example.com/", kivik.Options{
+		OptionHTTPClient:           &http.Client{Transport: customTransport(fn)},
+		OptionNoCompressedRequests: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// newTestKivikDB returns a kivik.DB named testdb, backed by this driver, which
+// sends all requests to fn.
+func newTestKivikDB(t *testing.T, fn func(*http.Request) (*http.Response, error)) *kivik.DB {
+	t.Helper()
+	return newTestKivikClient(t, fn).DB("testdb")
+}
+
 func Body(str string) io.ReadCloser {
 	if !strings.HasSuffix(str, "\n") {
 		str += "\n"
@@ -81,6 +109,15 @@ func Body(str string) io.ReadCloser {
 	return io.NopCloser(strings.NewReader(str))
 }
 
+// gzipped returns str, compressed with gzip.
+func gzipped(str string) string {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	_, _ = gz.Write([]byte(str))
+	_ = gz.Close()
+	return buf.String()
+}
+
 func parseTime(t *testing.T, str string) time.Time {
 	ts, err := time.Parse(time.RFC3339, str)
 	if err != nil {
@@ -144,3 +181,476 @@ func realDBConnect(t *testing.T) (*db, error) {
 		dbName: dbname,
 	}, err
 }
+
+// jsonResponse returns a response with the given status, and body encoded as
+// JSON.
+func jsonResponse(status int, body interface{}) (*http.Response, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       Body(string(data)),
+	}, nil
+}
+
+// notFoundResponse returns a 404 response.
+func notFoundResponse() (*http.Response, error) {
+	return jsonResponse(http.StatusNotFound, map[string]string{"error": "not_found", "reason": "missing"})
+}
+
+// fakeDoc is a document of a fakeDB. The first leaf revision is the winning
+// revision, whose content is body, if set.
+type fakeDoc struct {
+	leaves      []string
+	deleted     bool
+	attachments map[string]string // digests, by filename
+	body        map[string]interface{}
+	seq         int
+}
+
+func (d *fakeDoc) hasRev(rev string) bool {
+	for _, r := range d.leaves {
+		if r == rev {
+			return true
+		}
+	}
+	return false
+}
+
+// fakeDB is a database of fakeCouch.
+type fakeDB struct {
+	seq     int
+	docs    map[string]*fakeDoc
+	local   map[string]map[string]interface{}
+	indexes map[string]interface{}
+	// sticky revisions are reported purged by _purge, but are not removed.
+	sticky map[string]bool
+	// requests records the method and path of each request which is not a
+	// GET or HEAD.
+	requests []string
+	// purges records the body of each _purge request.
+	purges []map[string][]string
+	// newEdits records the new_edits option of each _bulk_docs request.
+	newEdits []interface{}
+}
+
+// init initializes the nil maps of d, and assigns sequences, in ID order, to
+// documents which have none.
+func (d *fakeDB) init() {
+	if d.docs == nil {
+		d.docs = map[string]*fakeDoc{}
+	}
+	if d.local == nil {
+		d.local = map[string]map[string]interface{}{}
+	}
+	if d.indexes == nil {
+		d.indexes = map[string]interface{}{}
+	}
+	for _, id := range d.sortedIDs() {
+		if d.docs[id].seq == 0 {
+			d.seq++
+			d.docs[id].seq = d.seq
+		}
+	}
+}
+
+func (d *fakeDB) sortedIDs() []string {
+	ids := make([]string, 0, len(d.docs))
+	for id := range d.docs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// put stores body as the only leaf revision of its document.
+func (d *fakeDB) put(body map[string]interface{}) {
+	d.init()
+	d.seq++
+	rev, _ := body["_rev"].(string)
+	deleted, _ := body["_deleted"].(bool)
+	d.docs[body["_id"].(string)] = &fakeDoc{
+		leaves:  []string{rev},
+		deleted: deleted,
+		body:    body,
+		seq:     d.seq,
+	}
+}
+
+// fakeLeaves returns documents with the given leaf revisions, by document ID.
+func fakeLeaves(revs map[string][]string) map[string]*fakeDoc {
+	docs := make(map[string]*fakeDoc, len(revs))
+	for id, leaves := range revs {
+		docs[id] = &fakeDoc{leaves: leaves}
+	}
+	return docs
+}
+
+// fakeCouch is a minimal in-memory CouchDB server, serving the endpoints used
+// by the replication, purge and migration helpers.
+type fakeCouch struct {
+	dbs map[string]*fakeDB
+	// config holds configuration values by "section/key".
+	config map[string]string
+}
+
+func (s *fakeCouch) handle(req *http.Request) (*http.Response, error) {
+	if key := strings.TrimPrefix(req.URL.Path, "/_node/_local/_config/"); key != req.URL.Path {
+		value, ok := s.config[key]
+		if !ok {
+			return jsonResponse(http.StatusNotFound, map[string]string{"error": "not_found", "reason": "unknown_config_value"})
+		}
+		return jsonResponse(http.StatusOK, value)
+	}
+	parts := strings.SplitN(strings.TrimPrefix(req.URL.Path, "/"), "/", 2)
+	db, ok := s.dbs[parts[0]]
+	if !ok || len(parts) != 2 {
+		return nil, fmt.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+	}
+	db.init()
+	path := parts[1]
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		db.requests = append(db.requests, req.Method+" "+path)
+	}
+	switch {
+	case path == "_changes":
+		return db.changes(req)
+	case path == "_revs_diff":
+		return db.revsDiff(req)
+	case path == "_all_docs":
+		return db.allDocs(req)
+	case path == "_bulk_get":
+		return db.bulkGet(req)
+	case path == "_bulk_docs":
+		return db.bulkDocs(req)
+	case path == "_purge":
+		return db.purge(req)
+	case path == "_index" || strings.HasPrefix(path, "_index/"):
+		return db.index(req, path)
+	case path == "_design_docs":
+		return db.designDocs()
+	case strings.Contains(path, "/_view/"):
+		if _, ok := db.docs[strings.SplitN(path, "/_view/", 2)[0]]; !ok {
+			return notFoundResponse()
+		}
+		return jsonResponse(http.StatusOK, map[string]interface{}{"total_rows": 0, "offset": 0, "rows": []interface{}{}})
+	case strings.HasPrefix(path, "_design/"):
+		return db.designDoc(req, path)
+	case strings.HasPrefix(path, "_local/"):
+		return db.localDoc(req, path)
+	}
+	return nil, fmt.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+}
+
+// changes serves a style=all_docs changes feed, honoring since and limit.
+func (d *fakeDB) changes(req *http.Request) (*http.Response, error) {
+	if req.URL.Query().Get("style") != "all_docs" {
+		return nil, fmt.Errorf("expected style=all_docs")
+	}
+	since, _ := strconv.Atoi(req.URL.Query().Get("since"))
+	limit, _ := strconv.Atoi(req.URL.Query().Get("limit"))
+	ids := make([]string, 0, len(d.docs))
+	for id, doc := range d.docs {
+		if doc.seq > since {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return d.docs[ids[i]].seq < d.docs[ids[j]].seq })
+	pending := len(ids)
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+	}
+	results := make([]map[string]interface{}, 0, len(ids))
+	lastSeq := since
+	for _, id := range ids {
+		doc := d.docs[id]
+		lastSeq = doc.seq
+		changes := make([]map[string]string, 0, len(doc.leaves))
+		for _, rev := range doc.leaves {
+			changes = append(changes, map[string]string{"rev": rev})
+		}
+		results = append(results, map[string]interface{}{
+			"seq":     strconv.Itoa(doc.seq),
+			"id":      id,
+			"changes": changes,
+			"deleted": doc.deleted,
+		})
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{
+		"results":  results,
+		"last_seq": strconv.Itoa(lastSeq),
+		"pending":  pending - len(ids),
+	})
+}
+
+func (d *fakeDB) revsDiff(req *http.Request) (*http.Response, error) {
+	var revMap map[string][]string
+	if err := json.NewDecoder(req.Body).Decode(&revMap); err != nil {
+		return nil, err
+	}
+	result := map[string]interface{}{}
+	for id, revs := range revMap {
+		var missing []string
+		for _, rev := range revs {
+			if doc, ok := d.docs[id]; !ok || !doc.hasRev(rev) {
+				missing = append(missing, rev)
+			}
+		}
+		if len(missing) > 0 {
+			result[id] = map[string]interface{}{"missing": missing}
+		}
+	}
+	return jsonResponse(http.StatusOK, result)
+}
+
+// allDocs serves _all_docs for a list of keys, including the documents.
+func (d *fakeDB) allDocs(req *http.Request) (*http.Response, error) {
+	var body struct {
+		Keys []string `json:"keys"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	rows := make([]map[string]interface{}, 0, len(body.Keys))
+	for _, id := range body.Keys {
+		doc, ok := d.docs[id]
+		switch {
+		case !ok:
+			rows = append(rows, map[string]interface{}{"key": id, "error": "not_found"})
+		case doc.deleted:
+			rows = append(rows, map[string]interface{}{"id": id, "key": id, "value": map[string]interface{}{"rev": doc.leaves[0], "deleted": true}, "doc": nil})
+		default:
+			atts := map[string]interface{}{}
+			for name, digest := range doc.attachments {
+				atts[name] = map[string]interface{}{"digest": digest, "stub": true}
+			}
+			rows = append(rows, map[string]interface{}{
+				"id": id, "key": id,
+				"value": map[string]interface{}{"rev": doc.leaves[0]},
+				"doc":   map[string]interface{}{"_id": id, "_rev": doc.leaves[0], "_attachments": atts},
+			})
+		}
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{"rows": rows})
+}
+
+func (d *fakeDB) bulkGet(req *http.Request) (*http.Response, error) {
+	if q := req.URL.Query(); q.Get("revs") != "true" || q.Get("attachments") != "true" {
+		return nil, fmt.Errorf("expected revs=true and attachments=true")
+	}
+	var body struct {
+		Docs []struct {
+			ID  string `json:"id"`
+			Rev string `json:"rev"`
+		} `json:"docs"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	results := make([]map[string]interface{}, 0, len(body.Docs))
+	for _, ref := range body.Docs {
+		doc, ok := d.docs[ref.ID]
+		if !ok || doc.body == nil || doc.leaves[0] != ref.Rev {
+			results = append(results, map[string]interface{}{"id": ref.ID, "docs": []interface{}{
+				map[string]interface{}{"error": map[string]string{"id": ref.ID, "rev": ref.Rev, "error": "not_found", "reason": "missing"}},
+			}})
+			continue
+		}
+		results = append(results, map[string]interface{}{"id": ref.ID, "docs": []interface{}{
+			map[string]interface{}{"ok": doc.body},
+		}})
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{"results": results})
+}
+
+// bulkDocs stores each document, except one with the ID "forbidden", which is
+// rejected.
+func (d *fakeDB) bulkDocs(req *http.Request) (*http.Response, error) {
+	var body struct {
+		Docs     []map[string]interface{} `json:"docs"`
+		NewEdits interface{}              `json:"new_edits"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	d.newEdits = append(d.newEdits, body.NewEdits)
+	results := []interface{}{}
+	for _, doc := range body.Docs {
+		if doc["_id"] == "forbidden" {
+			results = append(results, map[string]string{"id": "forbidden", "error": "forbidden", "reason": "no"})
+			continue
+		}
+		d.put(doc)
+	}
+	return jsonResponse(http.StatusCreated, results)
+}
+
+func (d *fakeDB) purge(req *http.Request) (*http.Response, error) {
+	var revMap map[string][]string
+	if err := json.NewDecoder(req.Body).Decode(&revMap); err != nil {
+		return nil, err
+	}
+	d.purges = append(d.purges, revMap)
+	purged := map[string][]string{}
+	for id, revs := range revMap {
+		doc, ok := d.docs[id]
+		if !ok {
+			continue
+		}
+		for _, rev := range revs {
+			if !doc.hasRev(rev) {
+				continue
+			}
+			purged[id] = append(purged[id], rev)
+			if d.sticky[rev] {
+				continue
+			}
+			leaves := doc.leaves[:0]
+			for _, r := range doc.leaves {
+				if r != rev {
+					leaves = append(leaves, r)
+				}
+			}
+			doc.leaves = leaves
+		}
+		if len(doc.leaves) == 0 {
+			delete(d.docs, id)
+		}
+	}
+	return jsonResponse(http.StatusCreated, map[string]interface{}{"purge_seq": nil, "purged": purged})
+}
+
+// index serves the Mango index endpoints. Indexes are stored by
+// "ddoc/name".
+func (d *fakeDB) index(req *http.Request, path string) (*http.Response, error) {
+	switch {
+	case path == "_index" && req.Method == http.MethodGet:
+		indexes := []interface{}{
+			map[string]interface{}{"ddoc": nil, "name": "_all_docs", "type": "special", "def": map[string]interface{}{"fields": []interface{}{map[string]string{"_id": "asc"}}}},
+		}
+		for _, key := range sortedKeys(d.indexes) {
+			ddoc, name := splitIndexKey(key)
+			indexes = append(indexes, map[string]interface{}{"ddoc": ddoc, "name": name, "type": "json", "def": d.indexes[key]})
+		}
+		return jsonResponse(http.StatusOK, map[string]interface{}{"total_rows": len(indexes), "indexes": indexes})
+	case path == "_index" && req.Method == http.MethodPost:
+		var body struct {
+			DDoc  string      `json:"ddoc"`
+			Name  string      `json:"name"`
+			Index interface{} `json:"index"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		var def map[string]interface{}
+		if err := normalizeJSON(body.Index, &def); err != nil {
+			return nil, err
+		}
+		d.indexes[body.DDoc+"/"+body.Name] = normalizeIndex(def)
+		return jsonResponse(http.StatusOK, map[string]string{"result": "created"})
+	case req.Method == http.MethodDelete:
+		key := strings.Replace(strings.TrimPrefix(path, "_index/"), "/json/", "/", 1)
+		if _, ok := d.indexes[key]; !ok {
+			return notFoundResponse()
+		}
+		delete(d.indexes, key)
+		return jsonResponse(http.StatusOK, map[string]bool{"ok": true})
+	}
+	return nil, fmt.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+}
+
+func (d *fakeDB) designDocs() (*http.Response, error) {
+	rows := []interface{}{}
+	for _, id := range d.sortedIDs() {
+		if !strings.HasPrefix(id, "_design/") {
+			continue
+		}
+		doc := d.docs[id]
+		rows = append(rows, map[string]interface{}{"id": id, "key": id, "value": map[string]interface{}{"rev": doc.leaves[0]}, "doc": doc.body})
+	}
+	return jsonResponse(http.StatusOK, map[string]interface{}{"total_rows": len(rows), "offset": 0, "rows": rows})
+}
+
+// designDoc serves GET, PUT and DELETE of a design document. New revisions
+// are numbered by the count of requests received.
+func (d *fakeDB) designDoc(req *http.Request, id string) (*http.Response, error) {
+	doc, exists := d.docs[id]
+	var rev string
+	if exists {
+		rev = doc.leaves[0]
+	}
+	var resp *http.Response
+	var err error
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		if !exists {
+			return notFoundResponse()
+		}
+		resp, err = jsonResponse(http.StatusOK, doc.body)
+	case http.MethodPut:
+		var body map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		if body["_rev"] != nil && body["_rev"] != rev || body["_rev"] == nil && exists {
+			return jsonResponse(http.StatusConflict, map[string]string{"error": "conflict", "reason": "Document update conflict."})
+		}
+		rev = fmt.Sprintf("%d-x", len(d.requests))
+		body["_id"] = id
+		body["_rev"] = rev
+		d.put(body)
+		resp, err = jsonResponse(http.StatusCreated, map[string]interface{}{"ok": true, "id": id, "rev": rev})
+	case http.MethodDelete:
+		if !exists {
+			return notFoundResponse()
+		}
+		if req.URL.Query().Get("rev") != rev {
+			return jsonResponse(http.StatusConflict, map[string]string{"error": "conflict", "reason": "Document update conflict."})
+		}
+		delete(d.docs, id)
+		rev = "x"
+		resp, err = jsonResponse(http.StatusOK, map[string]interface{}{"ok": true, "id": id, "rev": rev})
+	default:
+		return nil, fmt.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+	}
+	if err == nil {
+		resp.Header.Set("ETag", `"`+rev+`"`)
+	}
+	return resp, err
+}
+
+// localDoc serves GET and PUT of a local document. Local revisions are
+// numbered 0-1, 0-2, and so on.
+func (d *fakeDB) localDoc(req *http.Request, id string) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	switch req.Method {
+	case http.MethodGet:
+		doc, ok := d.local[id]
+		if !ok {
+			return notFoundResponse()
+		}
+		resp, err = jsonResponse(http.StatusOK, doc)
+	case http.MethodPut:
+		var doc map[string]interface{}
+		if err := json.NewDecoder(req.Body).Decode(&doc); err != nil {
+			return nil, err
+		}
+		n := 0
+		if rev, ok := doc["_rev"].(string); ok {
+			n, _ = strconv.Atoi(strings.TrimPrefix(rev, "0-"))
+		}
+		doc["_rev"] = fmt.Sprintf("0-%d", n+1)
+		d.local[id] = doc
+		resp, err = jsonResponse(http.StatusCreated, map[string]interface{}{"ok": true, "id": id, "rev": doc["_rev"]})
+	default:
+		return nil, fmt.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+	}
+	if err == nil {
+		resp.Header.Set("ETag", `"`+d.local[id]["_rev"].(string)+`"`)
+	}
+	return resp, err
+}
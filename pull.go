@@ -0,0 +1,350 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"crypto/md5" // nolint:gosec
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+const (
+	// defaultPullBatchSize is the number of changes replicated per batch
+	// when PullReplicator.BatchSize is not set.
+	defaultPullBatchSize = 100
+
+	// maxCheckpointHistory is the number of sessions kept in the history of
+	// a checkpoint, as by the server.
+	maxCheckpointHistory = 50
+)
+
+// PullReplicator replicates the documents of Source to Target, implementing
+// the CouchDB replication protocol in the client, rather than asking either
+// server to replicate. This allows replicating between servers which cannot
+// reach each other. Each batch of changes is read from the source, compared
+// with the target with _revs_diff, fetched from the source with _bulk_get,
+// and written to the target with _bulk_docs and new_edits=false. Progress is
+// checkpointed in a _local document on both sides, compatible with those of
+// the server's replicator, so that an interrupted replication resumes where
+// it left off.
+//
+// Example:
+//
+//	r := &couchdb.PullReplicator{
+//	    Source: remote.DB("src"),
+//	    Target: local.DB("tgt"),
+//	}
+//	session, err := r.Replicate(ctx)
+type PullReplicator struct {
+	Source *kivik.DB
+	Target *kivik.DB
+
+	// ReplicationID identifies the checkpoints of the replication. If empty,
+	// an ID is derived from the addresses and names of the databases.
+	ReplicationID string
+
+	// BatchSize is the number of changes replicated per batch. The default
+	// is 100.
+	BatchSize int
+
+	// SinceSeq, if set, is the source sequence from which to start the
+	// replication, rather than from a checkpoint.
+	SinceSeq string
+
+	// NoCheckpoints, if true, disables reading and writing checkpoints.
+	NoCheckpoints bool
+}
+
+// replicationCheckpoint is the _local document which records the progress of
+// a replication.
+type replicationCheckpoint struct {
+	ID            string               `json:"_id"`
+	Rev           string               `json:"_rev,omitempty"`
+	SessionID     string               `json:"session_id"`
+	SourceLastSeq sequenceID           `json:"source_last_seq"`
+	History       []ReplicationHistory `json:"history"`
+}
+
+// pullSession is the state of a single run of a PullReplicator.
+type pullSession struct {
+	*PullReplicator
+	checkpointID string
+	stats        ReplicationHistory
+	source       *replicationCheckpoint
+	target       *replicationCheckpoint
+}
+
+// Replicate replicates the changes of Source since the last checkpoint, or
+// since SinceSeq, and returns the statistics of the session. It returns once
+// all changes have been replicated; call it again to replicate later changes.
+func (r *PullReplicator) Replicate(ctx context.Context) (*ReplicationHistory, error) {
+	if r.Source == nil {
+		return nil, missingArg("Source")
+	}
+	if r.Target == nil {
+		return nil, missingArg("Target")
+	}
+	sessionID, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+	s := &pullSession{
+		PullReplicator: r,
+		checkpointID:   "_local/" + r.replicationID(),
+		stats: ReplicationHistory{
+			SessionID: sessionID,
+			StartTime: time.Now().UTC(),
+		},
+	}
+	if err := s.start(ctx); err != nil {
+		return nil, err
+	}
+	batchSize := r.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultPullBatchSize
+	}
+	since := s.stats.StartLastSeq
+	for {
+		lastSeq, count, err := s.replicateBatch(ctx, since, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			break
+		}
+		since = lastSeq
+		if err := s.checkpoint(ctx, since); err != nil {
+			return nil, err
+		}
+	}
+	s.stats.EndLastSeq = since
+	s.stats.EndTime = time.Now().UTC()
+	return &s.stats, nil
+}
+
+func (r *PullReplicator) replicationID() string {
+	if r.ReplicationID != "" {
+		return r.ReplicationID
+	}
+	h := md5.New() // nolint:gosec
+	for _, db := range []*kivik.DB{r.Source, r.Target} {
+		if c := db.Client(); c != nil {
+			_, _ = fmt.Fprintln(h, c.DSN())
+		}
+		_, _ = fmt.Fprintln(h, db.Name())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func newSessionID() (string, error) {
+	id := make([]byte, 16) // nolint:gomnd
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// start reads the checkpoints, and sets the sequence from which to start.
+func (s *pullSession) start(ctx context.Context) error {
+	s.stats.StartLastSeq = "0"
+	if s.NoCheckpoints {
+		if s.SinceSeq != "" {
+			s.stats.StartLastSeq = s.SinceSeq
+		}
+		return nil
+	}
+	var err error
+	if s.source, err = readCheckpoint(ctx, s.Source, s.checkpointID); err != nil {
+		return err
+	}
+	if s.target, err = readCheckpoint(ctx, s.Target, s.checkpointID); err != nil {
+		return err
+	}
+	if s.SinceSeq != "" {
+		s.stats.StartLastSeq = s.SinceSeq
+		return nil
+	}
+	s.stats.StartLastSeq = commonCheckpoint(s.source, s.target)
+	return nil
+}
+
+// readCheckpoint returns the checkpoint id of db, or an empty checkpoint if it
+// does not exist.
+func readCheckpoint(ctx context.Context, db *kivik.DB, id string) (*replicationCheckpoint, error) {
+	cp := &replicationCheckpoint{}
+	err := db.Get(ctx, id).ScanDoc(cp)
+	if kivik.HTTPStatus(err) == http.StatusNotFound {
+		return &replicationCheckpoint{ID: id}, nil
+	}
+	return cp, err
+}
+
+// commonCheckpoint returns the last sequence recorded by both source and
+// target, or "0" if they have no session in common.
+func commonCheckpoint(source, target *replicationCheckpoint) string {
+	if source.SessionID == "" || target.SessionID == "" {
+		return "0"
+	}
+	if source.SessionID == target.SessionID {
+		return string(source.SourceLastSeq)
+	}
+	sessions := make(map[string]struct{}, len(source.History))
+	for _, h := range source.History {
+		sessions[h.SessionID] = struct{}{}
+	}
+	for _, h := range target.History {
+		if _, ok := sessions[h.SessionID]; ok {
+			return h.RecordedSeq
+		}
+	}
+	return "0"
+}
+
+// replicateBatch replicates up to limit changes since the sequence since, and
+// returns the last sequence of the batch, and the number of changes read.
+func (s *pullSession) replicateBatch(ctx context.Context, since string, limit int) (string, int, error) {
+	changes := s.Source.Changes(ctx, kivik.Options{
+		"style": "all_docs",
+		"since": since,
+		"limit": limit,
+	})
+	defer changes.Close() // nolint:errcheck
+	revMap := map[string][]string{}
+	var count int
+	for changes.Next() {
+		count++
+		revMap[changes.ID()] = changes.Changes()
+	}
+	if err := changes.Err(); err != nil {
+		return "", 0, err
+	}
+	if count == 0 {
+		return since, 0, nil
+	}
+	meta, err := changes.Metadata()
+	if err != nil {
+		return "", 0, err
+	}
+	for _, revs := range revMap {
+		s.stats.MissingChecked += int64(len(revs))
+	}
+	missing, err := revsDiffMissing(ctx, s.Target, revMap)
+	if err != nil {
+		return "", 0, err
+	}
+	refs := make([]kivik.BulkGetReference, 0, len(missing))
+	for id, revs := range missing {
+		s.stats.MissingFound += int64(len(revs))
+		for _, rev := range revs {
+			refs = append(refs, kivik.BulkGetReference{ID: id, Rev: rev})
+		}
+	}
+	if len(refs) > 0 {
+		docs, err := s.fetch(ctx, refs)
+		if err != nil {
+			return "", 0, err
+		}
+		if err := s.write(ctx, docs); err != nil {
+			return "", 0, err
+		}
+	}
+	return meta.LastSeq, count, nil
+}
+
+// fetch reads the requested revisions from the source, with their revision
+// histories and attachments. Revisions which no longer exist, as after a
+// purge or compaction, are skipped.
+func (s *pullSession) fetch(ctx context.Context, refs []kivik.BulkGetReference) ([]interface{}, error) {
+	rows := s.Source.BulkGet(ctx, refs, kivik.Options{
+		"revs":        true,
+		"attachments": true,
+	})
+	defer rows.Close() // nolint:errcheck
+	docs := make([]interface{}, 0, len(refs))
+	for rows.Next() {
+		var doc map[string]interface{}
+		if err := rows.ScanDoc(&doc); err != nil {
+			if kivik.HTTPStatus(err) == http.StatusNotFound {
+				continue
+			}
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	s.stats.DocsRead += int64(len(docs))
+	return docs, nil
+}
+
+// write stores docs in the target, keeping their revisions.
+func (s *pullSession) write(ctx context.Context, docs []interface{}) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	results, err := s.Target.BulkDocs(ctx, docs, kivik.Options{"new_edits": false})
+	if err != nil && results == nil {
+		return err
+	}
+	var failures int64
+	for _, result := range results {
+		if result.Error != nil {
+			failures++
+		}
+	}
+	s.stats.DocWriteFailures += failures
+	s.stats.DocsWritten += int64(len(docs)) - failures
+	return nil
+}
+
+// checkpoint records seq as the last replicated sequence, on both sides.
+func (s *pullSession) checkpoint(ctx context.Context, seq string) error {
+	if s.NoCheckpoints {
+		return nil
+	}
+	s.stats.RecordedSeq = seq
+	s.stats.EndLastSeq = seq
+	s.stats.EndTime = time.Now().UTC()
+	for _, side := range []struct {
+		db *kivik.DB
+		cp *replicationCheckpoint
+	}{
+		{s.Source, s.source},
+		{s.Target, s.target},
+	} {
+		history := side.cp.History
+		if side.cp.SessionID == s.stats.SessionID && len(history) > 0 {
+			history = history[1:]
+		}
+		history = append([]ReplicationHistory{s.stats}, history...)
+		if len(history) > maxCheckpointHistory {
+			history = history[:maxCheckpointHistory]
+		}
+		side.cp.SessionID = s.stats.SessionID
+		side.cp.SourceLastSeq = sequenceID(seq)
+		side.cp.History = history
+		rev, err := side.db.Put(ctx, side.cp.ID, side.cp)
+		if err != nil {
+			return err
+		}
+		side.cp.Rev = rev
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestPullReplicatorReplicate(t *testing.T) {
+	source, target := &fakeDB{}, &fakeDB{}
+	source.put(map[string]interface{}{"_id": "a", "_rev": "1-a", "_revisions": map[string]interface{}{"start": 1, "ids": []string{"a"}}})
+	source.put(map[string]interface{}{"_id": "b", "_rev": "2-b", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"b", "a"}}, "foo": "bar"})
+	source.put(map[string]interface{}{"_id": "c", "_rev": "1-c", "_deleted": true})
+	source.put(map[string]interface{}{"_id": "forbidden", "_rev": "1-f"})
+	target.put(map[string]interface{}{"_id": "a", "_rev": "1-a"})
+	client := newTestKivikClient(t, (&fakeCouch{dbs: map[string]*fakeDB{"src": source, "tgt": target}}).handle)
+	r := &PullReplicator{
+		Source:        client.DB("src"),
+		Target:        client.DB("tgt"),
+		ReplicationID: "test",
+		BatchSize:     3,
+	}
+
+	ctx := context.Background()
+	session, err := r.Replicate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.StartLastSeq != "0" || session.EndLastSeq != "4" || session.RecordedSeq != "4" {
+		t.Errorf("Unexpected sequences: %s, %s, %s", session.StartLastSeq, session.EndLastSeq, session.RecordedSeq)
+	}
+	stats := [5]int64{session.MissingChecked, session.MissingFound, session.DocsRead, session.DocsWritten, session.DocWriteFailures}
+	if stats != [5]int64{4, 3, 3, 2, 1} {
+		t.Errorf("Unexpected stats: %v", stats)
+	}
+	if d := testy.DiffAsJSON(source.docs["b"].body, target.docs["b"].body); d != nil {
+		t.Errorf("Unexpected target doc:\n%s", d)
+	}
+	if _, ok := target.docs["c"]; !ok {
+		t.Error("Expected deleted document to be replicated")
+	}
+	for _, newEdits := range target.newEdits {
+		if newEdits != false {
+			t.Errorf("Unexpected new_edits: %v", newEdits)
+		}
+	}
+	for name, db := range map[string]*fakeDB{"source": source, "target": target} {
+		cp := db.local["_local/test"]
+		if cp["session_id"] != session.SessionID || cp["source_last_seq"] != "4" {
+			t.Errorf("Unexpected %s checkpoint: %v", name, cp)
+		}
+	}
+
+	// A second session resumes from the checkpoint.
+	source.put(map[string]interface{}{"_id": "d", "_rev": "1-d"})
+	second, err := r.Replicate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.StartLastSeq != "4" || second.EndLastSeq != "5" || second.DocsWritten != 1 {
+		t.Errorf("Unexpected second session: %+v", second)
+	}
+	var cp replicationCheckpoint
+	data, _ := json.Marshal(target.local["_local/test"])
+	if err := json.Unmarshal(data, &cp); err != nil {
+		t.Fatal(err)
+	}
+	if len(cp.History) != 2 || cp.History[0].SessionID != second.SessionID || cp.History[1].SessionID != session.SessionID {
+		t.Errorf("Unexpected history: %+v", cp.History)
+	}
+}
+
+func TestPullReplicatorErrors(t *testing.T) {
+	client := newTestKivikClient(t, func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("net error")
+	})
+	_, err := (&PullReplicator{}).Replicate(context.Background())
+	testy.StatusError(t, "kivik: Source required", http.StatusBadRequest, err)
+	_, err = (&PullReplicator{Source: client.DB("src")}).Replicate(context.Background())
+	testy.StatusError(t, "kivik: Target required", http.StatusBadRequest, err)
+	_, err = (&PullReplicator{Source: client.DB("src"), Target: client.DB("tgt")}).Replicate(context.Background())
+	testy.StatusErrorRE(t, "net error", http.StatusBadGateway, err)
+}
+
+func TestCommonCheckpoint(t *testing.T) {
+	tests := []struct {
+		name           string
+		source, target replicationCheckpoint
+		expected       string
+	}{
+		{
+			name:     "no checkpoints",
+			expected: "0",
+		},
+		{
+			name:     "same session",
+			source:   replicationCheckpoint{SessionID: "a", SourceLastSeq: "10"},
+			target:   replicationCheckpoint{SessionID: "a", SourceLastSeq: "10"},
+			expected: "10",
+		},
+		{
+			name: "common history",
+			source: replicationCheckpoint{SessionID: "c", SourceLastSeq: "30", History: []ReplicationHistory{
+				{SessionID: "c", RecordedSeq: "30"},
+				{SessionID: "a", RecordedSeq: "10"},
+			}},
+			target: replicationCheckpoint{SessionID: "b", SourceLastSeq: "20", History: []ReplicationHistory{
+				{SessionID: "b", RecordedSeq: "20"},
+				{SessionID: "a", RecordedSeq: "10"},
+			}},
+			expected: "10",
+		},
+		{
+			name:     "nothing in common",
+			source:   replicationCheckpoint{SessionID: "a", History: []ReplicationHistory{{SessionID: "a"}}},
+			target:   replicationCheckpoint{SessionID: "b", History: []ReplicationHistory{{SessionID: "b"}}},
+			expected: "0",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := commonCheckpoint(&test.source, &test.target); got != test.expected {
+				t.Errorf("Unexpected result: %s", got)
+			}
+		})
+	}
+}
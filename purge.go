@@ -0,0 +1,286 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/go-kivik/couchdb/v4/chttp"
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+const (
+	// defaultPurgeMaxDocs and defaultPurgeMaxRevs are the server's default
+	// limits on the number of documents and revisions of a purge request, used
+	// when the server's configuration cannot be read.
+	defaultPurgeMaxDocs = 100
+	defaultPurgeMaxRevs = 1000
+
+	// purgedInfosID and purgedInfosLimitID are the database endpoints which
+	// hold the purge history of a database, and its length. Get and Put read
+	// and write them as documents.
+	purgedInfosID      = "_purged_infos"
+	purgedInfosLimitID = "_purged_infos_limit"
+)
+
+// Purger purges document revisions in batches, each within the server's
+// limits on the size of a purge request, and optionally verifies afterwards
+// that the revisions are gone.
+//
+// Example:
+//
+//	p := &couchdb.Purger{DB: db, Verify: true}
+//	report, err := p.Purge(ctx, map[string][]string{
+//	    "user123": {"3-a7fd1b2c", "2-ee9b5c1d"},
+//	})
+//	if err == nil && len(report.Remaining) > 0 {
+//	    // Some revisions were not purged.
+//	}
+type Purger struct {
+	DB *kivik.DB
+
+	// MaxDocs and MaxRevs are the maximum numbers of documents and
+	// revisions per request. If unset, they are read from the server's
+	// purge/max_document_id_number and purge/max_revisions_number settings,
+	// or default to the server's defaults of 100 and 1000 if the settings
+	// cannot be read, as by users other than admins. The revisions of a
+	// document with more than MaxRevs revisions are split over several
+	// requests.
+	MaxDocs int
+	MaxRevs int
+
+	// Verify, if true, checks with _revs_diff that the requested revisions
+	// are no longer in the database, once purged.
+	Verify bool
+}
+
+// PurgeReport is the result of Purger.Purge.
+type PurgeReport struct {
+	// Batches is the number of purge requests made.
+	Batches int
+
+	// Purged are the revisions reported purged by the server, by document ID.
+	Purged map[string][]string
+
+	// Remaining are the requested revisions which are still in the database
+	// after the purge, by document ID. It is only set with Verify.
+	Remaining map[string][]string
+}
+
+// Purge purges the revisions of docRevs, a map of document IDs to revisions.
+// If a request fails, the report of the batches purged so far is returned
+// along with the error.
+func (p *Purger) Purge(ctx context.Context, docRevs map[string][]string) (*PurgeReport, error) {
+	if p.DB == nil {
+		return nil, missingArg("DB")
+	}
+	maxDocs, err := p.limit(ctx, p.MaxDocs, "max_document_id_number", defaultPurgeMaxDocs)
+	if err != nil {
+		return nil, err
+	}
+	maxRevs, err := p.limit(ctx, p.MaxRevs, "max_revisions_number", defaultPurgeMaxRevs)
+	if err != nil {
+		return nil, err
+	}
+	report := &PurgeReport{
+		Purged: map[string][]string{},
+	}
+	batches := purgeBatches(docRevs, maxDocs, maxRevs)
+	for _, batch := range batches {
+		result, err := p.DB.Purge(ctx, batch)
+		if err != nil {
+			return report, err
+		}
+		report.Batches++
+		for id, revs := range result.Purged {
+			report.Purged[id] = append(report.Purged[id], revs...)
+		}
+	}
+	if !p.Verify {
+		return report, nil
+	}
+	// Verify batch by batch, as _revs_diff requests are subject to the same
+	// size limits as purges.
+	remaining := map[string][]string{}
+	for _, batch := range batches {
+		batchRemaining, err := remainingRevs(ctx, p.DB, batch)
+		if err != nil {
+			return report, err
+		}
+		for id, revs := range batchRemaining {
+			remaining[id] = append(remaining[id], revs...)
+		}
+	}
+	report.Remaining = remaining
+	return report, nil
+}
+
+// limit returns value, if set, or else the purge setting key of the server,
+// or def if the setting cannot be read.
+func (p *Purger) limit(ctx context.Context, value int, key string, def int) (int, error) {
+	if value > 0 {
+		return value, nil
+	}
+	setting, err := p.DB.Client().ConfigValue(ctx, "_local", "purge", key)
+	switch kivik.HTTPStatus(err) {
+	case 0:
+	case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return def, nil
+	default:
+		return 0, err
+	}
+	n, err := strconv.Atoi(setting)
+	if err != nil || n <= 0 {
+		return 0, &kivik.Error{Status: http.StatusBadGateway, Err: fmt.Errorf("kivik: invalid purge/%s setting %q", key, setting)}
+	}
+	return n, nil
+}
+
+// purgeBatches splits docRevs into requests within maxDocs and maxRevs, in
+// document ID order.
+func purgeBatches(docRevs map[string][]string, maxDocs, maxRevs int) []map[string][]string {
+	ids := make([]string, 0, len(docRevs))
+	for id := range docRevs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var batches []map[string][]string
+	batch, revCount := map[string][]string{}, 0
+	for _, id := range ids {
+		revs := docRevs[id]
+		for len(revs) > 0 {
+			if len(batch) >= maxDocs || revCount >= maxRevs {
+				batches = append(batches, batch)
+				batch, revCount = map[string][]string{}, 0
+			}
+			n := len(revs)
+			if n > maxRevs-revCount {
+				n = maxRevs - revCount
+			}
+			batch[id] = revs[:n]
+			revCount += n
+			revs = revs[n:]
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// remainingRevs returns the revisions of docRevs which are still in db.
+func remainingRevs(ctx context.Context, db *kivik.DB, docRevs map[string][]string) (map[string][]string, error) {
+	missing, err := revsDiffMissing(ctx, db, docRevs)
+	if err != nil {
+		return nil, err
+	}
+	remaining := map[string][]string{}
+	for id, revs := range docRevs {
+		gone := make(map[string]struct{}, len(missing[id]))
+		for _, rev := range missing[id] {
+			gone[rev] = struct{}{}
+		}
+		for _, rev := range revs {
+			if _, ok := gone[rev]; !ok {
+				remaining[id] = append(remaining[id], rev)
+			}
+		}
+	}
+	return remaining, nil
+}
+
+// PurgedInfos is the record of the purges of a database, as returned by
+// GetPurgedInfos.
+type PurgedInfos struct {
+	// PurgeSeq is the purge sequence of the database. Clustered servers
+	// report none.
+	PurgeSeq string `json:"purge_seq"`
+
+	PurgedInfos []PurgedInfo `json:"purged_infos"`
+}
+
+// PurgedInfo is a purge request recorded by the server.
+type PurgedInfo struct {
+	ID   string   `json:"id"`
+	Revs []string `json:"revs"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface.
+func (i *PurgedInfos) UnmarshalJSON(data []byte) error {
+	type infosClone PurgedInfos
+	var x struct {
+		*infosClone
+		PurgeSeq *sequenceID `json:"purge_seq"`
+	}
+	x.infosClone = (*infosClone)(i)
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	if x.PurgeSeq != nil {
+		i.PurgeSeq = string(*x.PurgeSeq)
+	}
+	return nil
+}
+
+// getPurgedInfos reads docID, one of purgedInfosID or purgedInfosLimitID,
+// which have no revision.
+func (d *db) getPurgedInfos(ctx context.Context, docID string) (*driver.Document, error) {
+	resp, err := d.Client.DoReq(ctx, http.MethodGet, d.path(docID), nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := chttp.ResponseError(resp); err != nil {
+		return nil, err
+	}
+	return &driver.Document{Body: resp.Body}, nil
+}
+
+// GetPurgedInfos returns the purges recorded by db, of which the server keeps
+// the most recent, up to the limit returned by GetPurgedInfosLimit. It is
+// equivalent to reading the document _purged_infos with db.Get. Requires
+// CouchDB 2.3 or later.
+func GetPurgedInfos(ctx context.Context, db *kivik.DB) (*PurgedInfos, error) {
+	var infos PurgedInfos
+	if err := db.Get(ctx, purgedInfosID).ScanDoc(&infos); err != nil {
+		return nil, err
+	}
+	return &infos, nil
+}
+
+// GetPurgedInfosLimit returns the number of purges recorded by db. It is
+// equivalent to reading the document _purged_infos_limit with db.Get.
+func GetPurgedInfosLimit(ctx context.Context, db *kivik.DB) (int64, error) {
+	var limit int64
+	err := db.Get(ctx, purgedInfosLimitID).ScanDoc(&limit)
+	return limit, err
+}
+
+// SetPurgedInfosLimit sets the number of purges recorded by db. Purges are
+// also the only way for indexes and replicas to learn of purged revisions,
+// so the limit should not be lower than the number of purges made between
+// index updates. It is equivalent to writing the document
+// _purged_infos_limit with db.Put.
+func SetPurgedInfosLimit(ctx context.Context, db *kivik.DB, limit int64) error {
+	if limit <= 0 {
+		return &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: purged infos limit must be positive")}
+	}
+	_, err := db.Put(ctx, purgedInfosLimitID, limit)
+	return err
+}
@@ -0,0 +1,392 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestPurgerPurge(t *testing.T) {
+	type tst struct {
+		server   *fakeCouch
+		fn       func(*http.Request) (*http.Response, error)
+		purger   Purger
+		docRevs  map[string][]string
+		expected *PurgeReport
+		requests []map[string][]string
+		// revsDiffs is the expected number of _revs_diff requests.
+		revsDiffs int
+		status    int
+		err       string
+	}
+	tests := testy.NewTable()
+	tests.Add("missing db", tst{
+		status: http.StatusBadRequest,
+		err:    "kivik: DB required",
+	})
+	tests.Add("single batch", tst{
+		server: &fakeCouch{dbs: map[string]*fakeDB{
+			"testdb": {docs: fakeLeaves(map[string][]string{
+				"a": {"1-a", "2-a"},
+				"b": {"1-b"},
+			})},
+		}},
+		docRevs: map[string][]string{
+			"a": {"1-a", "2-a"},
+			"b": {"1-b"},
+		},
+		expected: &PurgeReport{
+			Batches: 1,
+			Purged: map[string][]string{
+				"a": {"1-a", "2-a"},
+				"b": {"1-b"},
+			},
+		},
+		requests: []map[string][]string{
+			{"a": {"1-a", "2-a"}, "b": {"1-b"}},
+		},
+	})
+	tests.Add("batched by docs", tst{
+		server: &fakeCouch{dbs: map[string]*fakeDB{
+			"testdb": {docs: fakeLeaves(map[string][]string{
+				"a": {"1-a"},
+				"b": {"1-b"},
+				"c": {"1-c"},
+			})},
+		}},
+		purger: Purger{MaxDocs: 2},
+		docRevs: map[string][]string{
+			"a": {"1-a"},
+			"b": {"1-b"},
+			"c": {"1-c"},
+		},
+		expected: &PurgeReport{
+			Batches: 2,
+			Purged: map[string][]string{
+				"a": {"1-a"},
+				"b": {"1-b"},
+				"c": {"1-c"},
+			},
+		},
+		requests: []map[string][]string{
+			{"a": {"1-a"}, "b": {"1-b"}},
+			{"c": {"1-c"}},
+		},
+	})
+	tests.Add("limits from server config", tst{
+		server: &fakeCouch{
+			dbs: map[string]*fakeDB{"testdb": {
+				docs: fakeLeaves(map[string][]string{
+					"a": {"1-a", "2-a", "3-a"},
+					"b": {"1-b"},
+					"c": {"1-c"},
+				}),
+			}},
+			config: map[string]string{
+				"purge/max_document_id_number": "2",
+				"purge/max_revisions_number":   "3",
+			},
+		},
+		docRevs: map[string][]string{
+			"a": {"1-a", "2-a", "3-a"},
+			"b": {"1-b"},
+			"c": {"1-c"},
+		},
+		expected: &PurgeReport{
+			Batches: 2,
+			Purged: map[string][]string{
+				"a": {"1-a", "2-a", "3-a"},
+				"b": {"1-b"},
+				"c": {"1-c"},
+			},
+		},
+		requests: []map[string][]string{
+			{"a": {"1-a", "2-a", "3-a"}},
+			{"b": {"1-b"}, "c": {"1-c"}},
+		},
+	})
+	tests.Add("invalid server config", tst{
+		server: &fakeCouch{
+			dbs:    map[string]*fakeDB{"testdb": {}},
+			config: map[string]string{"purge/max_document_id_number": "lots"},
+		},
+		docRevs: map[string][]string{"a": {"1-a"}},
+		status:  http.StatusBadGateway,
+		err:     `kivik: invalid purge/max_document_id_number setting "lots"`,
+	})
+	tests.Add("batched by revs", tst{
+		server: &fakeCouch{dbs: map[string]*fakeDB{
+			"testdb": {docs: fakeLeaves(map[string][]string{
+				"a": {"1-a", "2-a", "3-a"},
+				"b": {"1-b"},
+			})},
+		}},
+		purger: Purger{MaxRevs: 2},
+		docRevs: map[string][]string{
+			"a": {"1-a", "2-a", "3-a"},
+			"b": {"1-b"},
+		},
+		expected: &PurgeReport{
+			Batches: 2,
+			Purged: map[string][]string{
+				"a": {"1-a", "2-a", "3-a"},
+				"b": {"1-b"},
+			},
+		},
+		requests: []map[string][]string{
+			{"a": {"1-a", "2-a"}},
+			{"a": {"3-a"}, "b": {"1-b"}},
+		},
+	})
+	tests.Add("verify", tst{
+		server: &fakeCouch{
+			dbs: map[string]*fakeDB{"testdb": {
+				docs: fakeLeaves(map[string][]string{
+					"a": {"1-a"},
+					"b": {"1-b"},
+				}),
+				sticky: map[string]bool{"1-b": true},
+			}},
+		},
+		purger: Purger{Verify: true},
+		docRevs: map[string][]string{
+			"a": {"1-a"},
+			"b": {"1-b"},
+			"c": {"1-c"},
+		},
+		expected: &PurgeReport{
+			Batches: 1,
+			Purged: map[string][]string{
+				"a": {"1-a"},
+				"b": {"1-b"},
+			},
+			Remaining: map[string][]string{
+				"b": {"1-b"},
+			},
+		},
+		requests: []map[string][]string{
+			{"a": {"1-a"}, "b": {"1-b"}, "c": {"1-c"}},
+		},
+		revsDiffs: 1,
+	})
+	tests.Add("verify in batches", tst{
+		server: &fakeCouch{
+			dbs: map[string]*fakeDB{"testdb": {
+				docs: fakeLeaves(map[string][]string{
+					"a": {"1-a", "2-a", "3-a"},
+					"b": {"1-b"},
+				}),
+				sticky: map[string]bool{"3-a": true},
+			}},
+		},
+		purger: Purger{MaxDocs: 1, MaxRevs: 2, Verify: true},
+		docRevs: map[string][]string{
+			"a": {"1-a", "2-a", "3-a"},
+			"b": {"1-b"},
+		},
+		expected: &PurgeReport{
+			Batches: 3,
+			Purged: map[string][]string{
+				"a": {"1-a", "2-a", "3-a"},
+				"b": {"1-b"},
+			},
+			Remaining: map[string][]string{
+				"a": {"3-a"},
+			},
+		},
+		requests: []map[string][]string{
+			{"a": {"1-a", "2-a"}},
+			{"a": {"3-a"}},
+			{"b": {"1-b"}},
+		},
+		revsDiffs: 3,
+	})
+	tests.Add("purge error", tst{
+		fn: func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("purge failed")
+		},
+		purger:   Purger{MaxDocs: 1, MaxRevs: 1},
+		docRevs:  map[string][]string{"a": {"1-a"}},
+		expected: &PurgeReport{Purged: map[string][]string{}},
+		status:   http.StatusBadGateway,
+		err:      "purge failed",
+	})
+	tests.Add("verify error", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/_revs_diff") {
+				return nil, errors.New("revs_diff failed")
+			}
+			return (&fakeCouch{dbs: map[string]*fakeDB{
+				"testdb": {docs: fakeLeaves(map[string][]string{"a": {"1-a"}})},
+			}}).handle(req)
+		},
+		purger:  Purger{Verify: true},
+		docRevs: map[string][]string{"a": {"1-a"}},
+		expected: &PurgeReport{
+			Batches: 1,
+			Purged:  map[string][]string{"a": {"1-a"}},
+		},
+		status: http.StatusBadGateway,
+		err:    "revs_diff failed",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		fn := test.fn
+		if test.server != nil {
+			fn = test.server.handle
+		}
+		if fn != nil {
+			test.purger.DB = newTestKivikDB(t, fn)
+		}
+		report, err := test.purger.Purge(context.Background(), test.docRevs)
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		if d := testy.DiffInterface(test.expected, report); d != nil {
+			t.Error(d)
+		}
+		if test.server != nil {
+			db := test.server.dbs["testdb"]
+			if d := testy.DiffInterface(test.requests, db.purges); d != nil {
+				t.Errorf("Unexpected requests:\n%s", d)
+			}
+			var revsDiffs int
+			for _, req := range db.requests {
+				if req == "POST _revs_diff" {
+					revsDiffs++
+				}
+			}
+			if revsDiffs != test.revsDiffs {
+				t.Errorf("Unexpected _revs_diff requests: %d", revsDiffs)
+			}
+		}
+	})
+}
+
+func TestGetPurgedInfos(t *testing.T) {
+	type tst struct {
+		fn       func(*http.Request) (*http.Response, error)
+		expected *PurgedInfos
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("network error", tst{
+		fn: func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("net error")
+		},
+		status: http.StatusBadGateway,
+		err:    "Get \"?http://example.com/testdb/_purged_infos\"?: net error",
+	})
+	tests.Add("success", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path != "/testdb/_purged_infos" {
+				return nil, fmt.Errorf("unexpected path: %s", req.URL.Path)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       Body(`{"purge_seq":2,"purged_infos":[{"id":"a","revs":["1-a"]},{"id":"b","revs":["1-b","2-b"]}]}`),
+			}, nil
+		},
+		expected: &PurgedInfos{
+			PurgeSeq: "2",
+			PurgedInfos: []PurgedInfo{
+				{ID: "a", Revs: []string{"1-a"}},
+				{ID: "b", Revs: []string{"1-b", "2-b"}},
+			},
+		},
+	})
+	tests.Add("not found", tst{
+		fn: func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusNotFound,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       Body(`{"error":"not_found","reason":"Database does not exist."}`),
+			}, nil
+		},
+		status: http.StatusNotFound,
+		err:    "Not Found",
+	})
+	tests.Add("null purge_seq", tst{
+		fn: func(*http.Request) (*http.Response, error) {
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       Body(`{"purge_seq":null,"purged_infos":[]}`),
+			}, nil
+		},
+		expected: &PurgedInfos{PurgedInfos: []PurgedInfo{}},
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		infos, err := GetPurgedInfos(context.Background(), newTestKivikDB(t, test.fn))
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		if d := testy.DiffInterface(test.expected, infos); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestPurgedInfosLimit(t *testing.T) {
+	var limit int64 = 1000
+	db := newTestKivikDB(t, func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/testdb/_purged_infos_limit" {
+			return nil, fmt.Errorf("unexpected path: %s", req.URL.Path)
+		}
+		body := fmt.Sprintf("%d", limit)
+		if req.Method == http.MethodPut {
+			data, err := io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal(data, &limit); err != nil {
+				return nil, err
+			}
+			body = `{"ok":true}`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       Body(body),
+		}, nil
+	})
+	ctx := context.Background()
+
+	got, err := GetPurgedInfosLimit(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 1000 {
+		t.Errorf("Unexpected limit: %d", got)
+	}
+	if err := SetPurgedInfosLimit(ctx, db, 5000); err != nil {
+		t.Fatal(err)
+	}
+	got, err = GetPurgedInfosLimit(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 5000 {
+		t.Errorf("Unexpected limit after set: %d", got)
+	}
+	err = SetPurgedInfosLimit(ctx, db, 0)
+	testy.StatusError(t, "kivik: purged infos limit must be positive", http.StatusBadRequest, err)
+}
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"sync"
@@ -94,21 +95,20 @@ type replication struct {
 
 var _ driver.Replication = &replication{}
 
-func (c *client) fetchReplication(ctx context.Context, docID string) *replication {
-	rep := c.newReplication(docID)
-	rep.db = &db{client: c, dbName: "_replicator"}
+func (c *client) fetchReplication(ctx context.Context, dbName, docID string) *replication {
+	rep := c.newReplication(dbName, docID)
 	// Do an update to get the initial state, but don't fail if there's an error
 	// at this stage, because we successfully created the replication doc.
 	_ = rep.updateMain(ctx)
 	return rep
 }
 
-func (c *client) newReplication(docID string) *replication {
+func (c *client) newReplication(dbName, docID string) *replication {
 	return &replication{
 		docID: docID,
 		db: &db{
 			client: c,
-			dbName: "_replicator",
+			dbName: url.PathEscape(dbName),
 		},
 	}
 }
@@ -146,20 +146,125 @@ func (r *replication) Update(ctx context.Context, state *driver.ReplicationInfo)
 	state.DocWriteFailures = info.DocWriteFailures
 	state.DocsRead = info.DocsRead
 	state.DocsWritten = info.DocsWritten
-	// state.progress = info.Progress
+	state.Progress = replicationProgress(info.ChangesPending, info.CheckpointedSourceSeq, info.SourceSeq)
 	return nil
 }
 
+// replicationProgress estimates the percentage of a replication which is
+// complete. The number of changes processed is taken from the checkpointed
+// source sequence, whose numeric prefix CouchDB 2.x and later report as the
+// sum of the sequences of all source shards. When the number of pending
+// changes is known, it is the remaining work. Otherwise, the checkpointed
+// sequence is compared with the source sequence.
+func replicationProgress(pending *int64, checkpointed, source sequenceID) float64 {
+	done, ok := seqNumber(checkpointed)
+	if pending != nil {
+		switch {
+		case *pending == 0:
+			return 100
+		case !ok:
+			return 0
+		}
+		return float64(done) * 100 / float64(done+*pending) // nolint:gomnd
+	}
+	total, totalOK := seqNumber(source)
+	if !ok || !totalOK || total == 0 {
+		return 0
+	}
+	if done >= total {
+		return 100
+	}
+	return float64(done) * 100 / float64(total) // nolint:gomnd
+}
+
+// seqNumber returns the numeric prefix of an update sequence.
+func seqNumber(seq sequenceID) (int64, bool) {
+	num := strings.SplitN(string(seq), "-", 2)[0] // nolint:gomnd
+	n, err := strconv.ParseInt(num, 10, 64)       // nolint:gomnd
+	return n, err == nil
+}
+
+// ReplicationEstimator estimates the time remaining until a replication
+// completes, from the rate at which its progress advances between samples.
+// The progress of a replication is derived from its checkpointed source
+// sequence and pending changes, so the estimate follows the rate at which the
+// replication checkpoints the remaining work. The zero value is ready to use.
+// An estimator is meant for a single replication, and is not safe for
+// concurrent use.
+//
+// Example:
+//
+//	var est couchdb.ReplicationEstimator
+//	for rep.IsActive() {
+//	    if err := rep.Update(ctx); err != nil {
+//	        return err
+//	    }
+//	    est.Sample(rep)
+//	    if eta, ok := est.ETA(); ok {
+//	        fmt.Printf("%.0f%% complete, %s remaining\n", rep.Progress(), eta)
+//	    }
+//	    time.Sleep(time.Second)
+//	}
+type ReplicationEstimator struct {
+	first, last progressSample
+	sampled     bool
+}
+
+type progressSample struct {
+	time     time.Time
+	progress float64
+}
+
+// Sample records the progress of rep, as of its last Update.
+func (e *ReplicationEstimator) Sample(rep *kivik.Replication) {
+	e.sample(time.Now(), rep.Progress())
+}
+
+func (e *ReplicationEstimator) sample(now time.Time, progress float64) {
+	current := progressSample{time: now, progress: progress}
+	// A replication which starts over, without its checkpoints, or whose
+	// source has grown faster than it is replicated, starts a new
+	// measurement.
+	if !e.sampled || progress < e.last.progress {
+		e.first = current
+	}
+	e.last, e.sampled = current, true
+}
+
+// ETA returns the estimated time remaining, as of the last sample. ok is false
+// if no estimate is possible, such as before two samples with progress
+// between them, or once the replication is complete.
+func (e *ReplicationEstimator) ETA() (eta time.Duration, ok bool) {
+	elapsed := e.last.time.Sub(e.first.time)
+	advance := e.last.progress - e.first.progress
+	if elapsed <= 0 || advance <= 0 || e.last.progress >= 100 {
+		return 0, false
+	}
+	rate := advance / elapsed.Seconds()
+	return time.Duration((100 - e.last.progress) / rate * float64(time.Second)), true // nolint:gomnd
+}
+
 type activeTask struct {
 	Type             string `json:"type"`
 	ReplicationID    string `json:"replication_id"`
 	DocsWritten      int64  `json:"docs_written"`
 	DocsRead         int64  `json:"docs_read"`
 	DocWriteFailures int64  `json:"doc_write_failures"`
+
+	ChangesPending        *int64     `json:"changes_pending"`
+	CheckpointedSourceSeq sequenceID `json:"checkpointed_source_seq"`
+	SourceSeq             sequenceID `json:"source_seq"`
+
+	// Indexer tasks
+	Database       string `json:"database"`
+	DesignDocument string `json:"design_document"`
+	ChangesDone    int64  `json:"changes_done"`
+	TotalChanges   int64  `json:"total_changes"`
 }
 
-func (r *replication) updateActiveTasks(ctx context.Context) (*activeTask, error) {
-	resp, err := r.client.DoReq(ctx, http.MethodGet, "/_active_tasks", nil)
+// activeTasks fetches the tasks currently running on the server.
+func (c *client) activeTasks(ctx context.Context) ([]*activeTask, error) {
+	resp, err := c.DoReq(ctx, http.MethodGet, "/_active_tasks", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -171,12 +276,26 @@ func (r *replication) updateActiveTasks(ctx context.Context) (*activeTask, error
 	if err = json.NewDecoder(resp.Body).Decode(&tasks); err != nil {
 		return nil, &kivik.Error{Status: http.StatusBadGateway, Err: err}
 	}
+	return tasks, nil
+}
+
+func (r *replication) updateActiveTasks(ctx context.Context) (*activeTask, error) {
+	return r.client.replicationTask(ctx, r.replicationID)
+}
+
+// replicationTask returns the active task of the replication with the given
+// replication ID, or a 404 error if there is none.
+func (c *client) replicationTask(ctx context.Context, replicationID string) (*activeTask, error) {
+	tasks, err := c.activeTasks(ctx)
+	if err != nil {
+		return nil, err
+	}
 	for _, task := range tasks {
 		if task.Type != "replication" {
 			continue
 		}
 		repIDparts := strings.SplitN(task.ReplicationID, "+", 2) // nolint:gomnd
-		if repIDparts[0] != r.replicationID {
+		if repIDparts[0] != replicationID {
 			continue
 		}
 		return task, nil
@@ -220,10 +339,10 @@ func (r *replication) setFromReplicatorDoc(doc *replicatorDoc) {
 		r.err = nil
 	}
 	if r.source == "" {
-		r.source = doc.Source
+		r.source = string(doc.Source)
 	}
 	if r.target == "" {
-		r.target = doc.Target
+		r.target = string(doc.Target)
 	}
 	if r.replicationID == "" {
 		r.replicationID = doc.ReplicationID
@@ -242,25 +361,45 @@ func (r *replication) Delete(ctx context.Context) error {
 type replicatorDoc struct {
 	DocID         string               `json:"_id"`
 	ReplicationID string               `json:"_replication_id"`
-	Source        string               `json:"source"`
-	Target        string               `json:"target"`
+	Source        replicationEndpoint  `json:"source"`
+	Target        replicationEndpoint  `json:"target"`
 	State         string               `json:"_replication_state"`
 	StateTime     replicationStateTime `json:"_replication_state_time"`
 	Error         *replicationError    `json:"_replication_state_reason,omitempty"`
 }
 
+// GetReplications lists the replications of the server. With
+// OptionSchedulerJobs, it instead reads the jobs of the replication scheduler
+// into the value of the option, and returns no replications.
 func (c *client) GetReplications(ctx context.Context, options map[string]interface{}) ([]driver.Replication, error) {
+	jobs, err := schedulerJobsOption(options)
+	if err != nil {
+		return nil, err
+	}
+	if jobs != nil {
+		if _, ok := options[OptionReplicatorDB]; ok {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: options '%s' and '%s' are mutually exclusive", OptionSchedulerJobs, OptionReplicatorDB)}
+		}
+		return nil, c.getSchedulerJobs(ctx, jobs, options)
+	}
 	scheduler, err := c.schedulerSupported(ctx)
 	if err != nil {
 		return nil, err
 	}
+	dbName, err := replicatorDBOption(options)
+	if err != nil {
+		return nil, err
+	}
 	if scheduler {
-		return c.getReplicationsFromScheduler(ctx, options)
+		return c.getReplicationsFromScheduler(ctx, dbName, options)
+	}
+	if dbName == "" {
+		dbName = defaultReplicatorDB
 	}
-	return c.legacyGetReplications(ctx, options)
+	return c.legacyGetReplications(ctx, dbName, options)
 }
 
-func (c *client) legacyGetReplications(ctx context.Context, options map[string]interface{}) ([]driver.Replication, error) {
+func (c *client) legacyGetReplications(ctx context.Context, dbName string, options map[string]interface{}) ([]driver.Replication, error) {
 	if options == nil {
 		options = map[string]interface{}{}
 	}
@@ -276,7 +415,7 @@ func (c *client) legacyGetReplications(ctx context.Context, options map[string]i
 			Doc replicatorDoc `json:"doc"`
 		} `json:"rows"`
 	}
-	path := "/_replicator/_all_docs?" + params.Encode()
+	path := "/" + url.PathEscape(dbName) + "/_all_docs?" + params.Encode()
 	if err = c.DoJSON(ctx, http.MethodGet, path, nil, &result); err != nil {
 		return nil, err
 	}
@@ -285,7 +424,7 @@ func (c *client) legacyGetReplications(ctx context.Context, options map[string]i
 		if row.Doc.DocID == "_design/_replicator" {
 			continue
 		}
-		rep := c.newReplication(row.Doc.DocID)
+		rep := c.newReplication(dbName, row.Doc.DocID)
 		rep.setFromReplicatorDoc(&row.Doc)
 		reps = append(reps, rep)
 	}
@@ -309,6 +448,28 @@ func (c *client) Replicate(ctx context.Context, targetDSN, sourceDSN string, opt
 	if s := options["source"]; s == "" {
 		return nil, missingArg("sourceDSN")
 	}
+	transient, result, err := transientOption(options)
+	if err != nil {
+		return nil, err
+	}
+	if transient {
+		if _, ok := options[OptionReplicatorDB]; ok {
+			return nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: option '%s' may not be combined with '%s'", OptionReplicatorDB, OptionTransientReplication)}
+		}
+		rep, err := c.replicateTransient(ctx, options, result)
+		if err != nil {
+			return nil, err
+		}
+		return rep, nil
+	}
+
+	dbName, err := replicatorDBOption(options)
+	if err != nil {
+		return nil, err
+	}
+	if dbName == "" {
+		dbName = defaultReplicatorDB
+	}
 
 	scheduler, err := c.schedulerSupported(ctx)
 	if err != nil {
@@ -321,11 +482,132 @@ func (c *client) Replicate(ctx context.Context, targetDSN, sourceDSN string, opt
 	var repStub struct {
 		ID string `json:"id"`
 	}
-	if e := c.Client.DoJSON(ctx, http.MethodPost, "/_replicator", opts, &repStub); e != nil {
+	if e := c.Client.DoJSON(ctx, http.MethodPost, "/"+url.PathEscape(dbName), opts, &repStub); e != nil {
 		return nil, e
 	}
 	if scheduler {
-		return c.fetchSchedulerReplication(ctx, repStub.ID)
+		return c.fetchSchedulerReplication(ctx, dbName, repStub.ID)
 	}
-	return c.fetchReplication(ctx, repStub.ID), nil
+	return c.fetchReplication(ctx, dbName, repStub.ID), nil
+}
+
+// defaultReplicatorDB is the replicator database used when
+// OptionReplicatorDB is not set.
+const defaultReplicatorDB = "_replicator"
+
+// replicatorDBOption returns the replicator database set with
+// OptionReplicatorDB, or "" if it is not set.
+func replicatorDBOption(opts map[string]interface{}) (string, error) {
+	i, ok := opts[OptionReplicatorDB]
+	if !ok {
+		return "", nil
+	}
+	dbName, _ := i.(string)
+	if dbName != defaultReplicatorDB && !strings.HasSuffix(dbName, "/"+defaultReplicatorDB) {
+		return "", &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: option '%s' must be a database name ending in _replicator, not %#v", OptionReplicatorDB, i)}
+	}
+	delete(opts, OptionReplicatorDB)
+	return dbName, nil
+}
+
+// ReplicationWaiter waits for replications to reach a terminal state, polling
+// their state with exponential backoff.
+//
+// Example:
+//
+//	rep, _ := client.Replicate(ctx, target, source)
+//	w := &couchdb.ReplicationWaiter{
+//	    OnStateChange: func(rep *kivik.Replication, from, to kivik.ReplicationState) {
+//	        log.Printf("replication %s: %s -> %s", rep.ReplicationID(), from, to)
+//	    },
+//	}
+//	err := w.Wait(ctx, rep)
+type ReplicationWaiter struct {
+	// OnStateChange, if set, is called each time the state of the replication
+	// changes, including the first time it is observed.
+	OnStateChange func(rep *kivik.Replication, from, to kivik.ReplicationState)
+
+	// MinInterval and MaxInterval bound the interval between polls. The
+	// interval starts at MinInterval, and doubles after each poll which
+	// observes no state change, up to MaxInterval. They default to 100ms and
+	// 10s respectively.
+	MinInterval, MaxInterval time.Duration
+}
+
+// Wait polls rep until it is completed or failed, or until ctx is done.
+// Continuous replications never complete, so Wait returns for them only on
+// failure, or when ctx is done. Wait returns nil once the replication is
+// completed, and the replication's error if it fails.
+func (w *ReplicationWaiter) Wait(ctx context.Context, rep *kivik.Replication) error {
+	minInterval, maxInterval := w.MinInterval, w.MaxInterval
+	if minInterval <= 0 {
+		minInterval = 100 * time.Millisecond // nolint:gomnd
+	}
+	if maxInterval <= 0 {
+		maxInterval = 10 * time.Second // nolint:gomnd
+	}
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
+	b := newBackoff(minInterval, maxInterval)
+	var state kivik.ReplicationState
+	observed := false
+	for {
+		err := rep.Update(ctx)
+		// The scheduler may not yet know about a new replication.
+		if err != nil && kivik.HTTPStatus(err) != http.StatusNotFound {
+			return err
+		}
+		if err == nil {
+			if to := rep.State(); !observed || to != state {
+				if w.OnStateChange != nil {
+					w.OnStateChange(rep, state, to)
+				}
+				state, observed = to, true
+				b.reset()
+			}
+			switch state {
+			case kivik.ReplicationComplete:
+				return nil
+			case kivik.ReplicationFailed, kivik.ReplicationError:
+				if err := rep.Err(); err != nil {
+					return err
+				}
+				return &kivik.Error{Status: http.StatusBadGateway, Err: fmt.Errorf("kivik: replication %s", state)}
+			}
+		}
+		if err := b.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff produces exponentially increasing delays, for polling.
+type backoff struct {
+	min, max, next time.Duration
+}
+
+func newBackoff(min, max time.Duration) *backoff {
+	return &backoff{min: min, max: max, next: min}
+}
+
+// reset restores the delay to its minimum.
+func (b *backoff) reset() {
+	b.next = b.min
+}
+
+// wait sleeps for the current delay, or until ctx is done, and then doubles
+// the delay, up to its maximum.
+func (b *backoff) wait(ctx context.Context) error {
+	t := time.NewTimer(b.next)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+	if b.next *= 2; b.next > b.max {
+		b.next = b.max
+	}
+	return nil
 }
@@ -322,7 +322,7 @@ func TestLegacyGetReplications(t *testing.T) {
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			reps, err := test.client.legacyGetReplications(context.Background(), test.options)
+			reps, err := test.client.legacyGetReplications(context.Background(), "_replicator", test.options)
 			testy.StatusErrorRE(t, test.err, test.status, err)
 			result := make([]*replication, len(reps))
 			for i, rep := range reps {
@@ -398,6 +398,48 @@ func TestGetReplications(t *testing.T) {
 	}
 }
 
+func TestGetReplicationsSchedulerJobs(t *testing.T) {
+	client := newTestKivikClient(t, func(req *http.Request) (*http.Response, error) {
+		if req.URL.Path != "/_scheduler/jobs" {
+			// Replications must not be listed in this mode.
+			return nil, fmt.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       Body(`{"total_rows":1,"offset":0,"jobs":[{"database":"_replicator","doc_id":"rep1","id":"a0f2e3c4+continuous","source":"s","target":"t","history":[],"node":"node1@127.0.0.1","start_time":"2017-04-29T05:01:37Z"}]}`),
+		}, nil
+	})
+	jobs := new(SchedulerJobs)
+	reps, err := client.GetReplications(context.Background(), kivik.Options{
+		OptionSchedulerJobs: jobs,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reps) != 0 {
+		t.Errorf("Expected no replications, got %d", len(reps))
+	}
+	expected := &SchedulerJobs{
+		TotalRows: 1,
+		Jobs: []SchedulerJob{
+			{
+				ID:        "a0f2e3c4+continuous",
+				Database:  "_replicator",
+				DocID:     "rep1",
+				Source:    "s",
+				Target:    "t",
+				StartTime: parseTime(t, "2017-04-29T05:01:37Z"),
+				Node:      "node1@127.0.0.1",
+				History:   []SchedulerJobEvent{},
+			},
+		},
+	}
+	if d := testy.DiffInterface(expected, jobs); d != nil {
+		t.Error(d)
+	}
+}
+
 func TestReplicationUpdate(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -453,6 +495,39 @@ func TestReplicationUpdate(t *testing.T) {
 			},
 			expected: &driver.ReplicationInfo{},
 		},
+		{
+			name: "active replication 2.x",
+			rep: &replication{
+				docID:         "4ab99e4d7d4b5a6c5a6df0d0ed01221d",
+				replicationID: "548507fbb9fb9fcd8a3b27050b9ba5bf",
+				db: newCustomDB(func(req *http.Request) (*http.Response, error) {
+					switch req.URL.Path {
+					case "/testdb/4ab99e4d7d4b5a6c5a6df0d0ed01221d":
+						return &http.Response{
+							StatusCode: 200,
+							Header: http.Header{
+								"ETag":         {`"2-6419706e969050d8000efad07259de4f"`},
+								"Content-Type": {"application/json"},
+							},
+							Body: Body(`{"_id":"4ab99e4d7d4b5a6c5a6df0d0ed01221d","_rev":"2-6419706e969050d8000efad07259de4f","source":"foo","target":"bar","_replication_state":"triggered","_replication_state_time":"2017-10-30T20:03:34+00:00","_replication_id":"548507fbb9fb9fcd8a3b27050b9ba5bf"}`),
+						}, nil
+					case "/_active_tasks":
+						return &http.Response{
+							StatusCode: 200,
+							Header:     http.Header{"Content-Type": {"application/json"}},
+							Body:       Body(`[{"type":"replication","replication_id":"548507fbb9fb9fcd8a3b27050b9ba5bf+continuous","docs_read":10,"docs_written":10,"changes_pending":30,"checkpointed_source_seq":"10-g1AAAA","source_seq":"40-g1AAAA"}]`),
+						}, nil
+					default:
+						panic("Unknown req path: " + req.URL.Path)
+					}
+				}),
+			},
+			expected: &driver.ReplicationInfo{
+				DocsRead:    10,
+				DocsWritten: 10,
+				Progress:    25,
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -753,3 +828,411 @@ func TestReplicationGetters(t *testing.T) {
 	}
 	testy.Error(t, err, rep.Err())
 }
+
+func TestReplicationProgress(t *testing.T) {
+	pending := func(n int64) *int64 { return &n }
+	tests := []struct {
+		name         string
+		pending      *int64
+		checkpointed sequenceID
+		source       sequenceID
+		expected     float64
+	}{
+		{
+			name: "nothing known",
+		},
+		{
+			name:     "nothing pending",
+			pending:  pending(0),
+			expected: 100,
+		},
+		{
+			name:         "pending",
+			pending:      pending(75),
+			checkpointed: "25-g1AAAA",
+			source:       "50-g1AAAA",
+			expected:     25,
+		},
+		{
+			name:    "pending, no checkpoint",
+			pending: pending(75),
+		},
+		{
+			name:         "1.x sequences",
+			checkpointed: "30",
+			source:       "40",
+			expected:     75,
+		},
+		{
+			name:         "source behind checkpoint",
+			checkpointed: "50-g1AAAA",
+			source:       "40-g1AAAA",
+			expected:     100,
+		},
+		{
+			name:         "invalid sequence",
+			checkpointed: "null",
+			source:       "40",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := replicationProgress(test.pending, test.checkpointed, test.source)
+			if result != test.expected {
+				t.Errorf("Unexpected progress: %v", result)
+			}
+		})
+	}
+}
+
+func TestReplicationEstimatorETA(t *testing.T) {
+	type sample struct {
+		offset   time.Duration
+		progress float64
+	}
+	tests := []struct {
+		name     string
+		samples  []sample
+		expected time.Duration
+		ok       bool
+	}{
+		{
+			name: "no samples",
+		},
+		{
+			name:    "single sample",
+			samples: []sample{{progress: 50}},
+		},
+		{
+			name: "no progress",
+			samples: []sample{
+				{progress: 50},
+				{offset: time.Minute, progress: 50},
+			},
+		},
+		{
+			name: "progress",
+			samples: []sample{
+				{progress: 40},
+				{offset: 10 * time.Second, progress: 50},
+			},
+			expected: 50 * time.Second,
+			ok:       true,
+		},
+		{
+			name: "rate since first sample",
+			samples: []sample{
+				{progress: 10},
+				{offset: 10 * time.Second, progress: 30},
+				{offset: 40 * time.Second, progress: 50},
+			},
+			expected: 50 * time.Second,
+			ok:       true,
+		},
+		{
+			name: "complete",
+			samples: []sample{
+				{progress: 90},
+				{offset: 10 * time.Second, progress: 100},
+			},
+		},
+		{
+			name: "restarted without checkpoint",
+			samples: []sample{
+				{progress: 90},
+				{offset: 10 * time.Second, progress: 5},
+				{offset: 20 * time.Second, progress: 10},
+			},
+			expected: 180 * time.Second,
+			ok:       true,
+		},
+	}
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var est ReplicationEstimator
+			for _, smp := range test.samples {
+				est.sample(start.Add(smp.offset), smp.progress)
+			}
+			eta, ok := est.ETA()
+			if ok != test.ok {
+				t.Errorf("Unexpected ok: %t", ok)
+			}
+			if eta != test.expected {
+				t.Errorf("Unexpected ETA: %s", eta)
+			}
+		})
+	}
+}
+
+func TestReplicationEstimatorSample(t *testing.T) {
+	const docTmpl = `{"database":"_replicator","doc_id":"foo","id":"eta","source":"http://localhost:5984/foo/","target":"http://localhost:5984/bar/","state":"running","info":%s,"error_count":0,"start_time":"2017-11-01T21:05:03Z","last_updated":"2017-11-01T21:05:06Z"}`
+	infos := []string{
+		`{"changes_pending":100,"checkpointed_source_seq":"900-x","docs_read":0}`,
+		`{"changes_pending":50,"checkpointed_source_seq":"950-x","docs_read":50}`,
+	}
+	var polls int
+	client := newTestKivikClient(t, func(req *http.Request) (*http.Response, error) {
+		var body string
+		switch req.URL.Path {
+		case "/_scheduler/jobs":
+			body = "{}"
+		case "/_scheduler/docs":
+			body = `{"docs":[` + fmt.Sprintf(docTmpl, "null") + `]}`
+		case "/_scheduler/docs/_replicator/foo":
+			body = fmt.Sprintf(docTmpl, infos[polls])
+			polls++
+		default:
+			return nil, fmt.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       Body(body),
+		}, nil
+	})
+	ctx := context.Background()
+	reps, err := client.GetReplications(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rep := reps[0]
+	var est ReplicationEstimator
+	if err := rep.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	est.Sample(rep)
+	if _, ok := est.ETA(); ok {
+		t.Error("Expected no estimate after a single update")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if err := rep.Update(ctx); err != nil {
+		t.Fatal(err)
+	}
+	est.Sample(rep)
+	// Progress advanced from 90% to 95%, so 5% remain.
+	eta, ok := est.ETA()
+	if !ok {
+		t.Fatal("Expected an estimate")
+	}
+	if eta < 10*time.Millisecond || eta > time.Second {
+		t.Errorf("Unexpected ETA: %s", eta)
+	}
+}
+
+func TestReplicationWaiterWait(t *testing.T) {
+	const docTmpl = `{"database":"_replicator","doc_id":"foo","id":"abc","source":"http://localhost:5984/foo/","target":"http://localhost:5984/bar/","state":%q,"info":%s,"error_count":0,"start_time":"2017-11-01T21:05:03Z","last_updated":"2017-11-01T21:05:06Z"}`
+	// scheduler simulates a server on which the replication passes through
+	// states, one per poll. A state of "404" simulates a replication not yet
+	// known to the scheduler.
+	scheduler := func(info string, states ...string) func(*http.Request) (*http.Response, error) {
+		var polls int
+		return func(req *http.Request) (*http.Response, error) {
+			var body string
+			switch req.URL.Path {
+			case "/_scheduler/jobs":
+				body = "{}"
+			case "/_scheduler/docs":
+				body = `{"docs":[` + fmt.Sprintf(docTmpl, "initializing", "null") + `]}`
+			case "/_scheduler/docs/_replicator/foo":
+				state := states[len(states)-1]
+				if polls < len(states) {
+					state = states[polls]
+				}
+				polls++
+				if state == "404" {
+					return &http.Response{
+						StatusCode: http.StatusNotFound,
+						Header:     http.Header{"Content-Type": {"application/json"}},
+						Body:       Body(`{"error":"not_found","reason":"unknown"}`),
+					}, nil
+				}
+				body = fmt.Sprintf(docTmpl, state, info)
+			default:
+				return nil, fmt.Errorf("unexpected request: %s %s", req.Method, req.URL.Path)
+			}
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       Body(body),
+			}, nil
+		}
+	}
+	type tst struct {
+		fn          func(*http.Request) (*http.Response, error)
+		timeout     time.Duration
+		transitions []string
+		status      int
+		err         string
+	}
+	tests := testy.NewTable()
+	tests.Add("completed", tst{
+		fn:          scheduler("null", "404", "running", "running", "crashing", "running", "completed"),
+		transitions: []string{"->running", "running->crashing", "crashing->running", "running->completed"},
+	})
+	tests.Add("failed with reason", tst{
+		fn:          scheduler(`"db_not_found: could not open foo"`, "pending", "failed"),
+		transitions: []string{"->pending", "pending->failed"},
+		status:      http.StatusNotFound,
+		err:         "db_not_found: could not open foo",
+	})
+	tests.Add("failed without reason", tst{
+		fn:          scheduler("null", "failed"),
+		transitions: []string{"->failed"},
+		status:      http.StatusBadGateway,
+		err:         "kivik: replication failed",
+	})
+	tests.Add("update error", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.URL.Path == "/_scheduler/docs/_replicator/foo" {
+				return nil, errors.New("update failed")
+			}
+			return scheduler("null", "running")(req)
+		},
+		status: http.StatusBadGateway,
+		err:    "update failed",
+	})
+	tests.Add("timeout", tst{
+		fn:          scheduler("null", "running"),
+		timeout:     20 * time.Millisecond,
+		transitions: []string{"->running"},
+		status:      http.StatusInternalServerError,
+		err:         "context deadline exceeded",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		ctx := context.Background()
+		if test.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, test.timeout)
+			defer cancel()
+		}
+		reps, err := newTestKivikClient(t, test.fn).GetReplications(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var transitions []string
+		w := &ReplicationWaiter{
+			OnStateChange: func(_ *kivik.Replication, from, to kivik.ReplicationState) {
+				transitions = append(transitions, string(from)+"->"+string(to))
+			},
+			MinInterval: time.Millisecond,
+			MaxInterval: 2 * time.Millisecond,
+		}
+		err = w.Wait(ctx, reps[0])
+		if d := testy.DiffInterface(test.transitions, transitions); d != nil {
+			t.Errorf("Unexpected transitions:\n%s", d)
+		}
+		testy.StatusErrorRE(t, test.err, test.status, err)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff(time.Millisecond, 3*time.Millisecond)
+	var delays []time.Duration
+	for i := 0; i < 4; i++ {
+		delays = append(delays, b.next)
+		if err := b.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.reset()
+	delays = append(delays, b.next)
+	want := []time.Duration{time.Millisecond, 2 * time.Millisecond, 3 * time.Millisecond, 3 * time.Millisecond, time.Millisecond}
+	if d := testy.DiffInterface(want, delays); d != nil {
+		t.Error(d)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.wait(ctx); err != context.Canceled {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestReplicatorDB(t *testing.T) {
+	const (
+		schedulerDoc  = `{"database":"tenantA/_replicator","doc_id":"foo","id":"abc","source":"http://localhost:5984/src","target":"http://localhost:5984/tgt","state":"running","start_time":"2017-11-18T11:13:58Z","last_updated":"2017-11-18T11:13:58Z","info":{"docs_read":1}}`
+		replicatorDoc = `{"_id":"foo","_rev":"1-abc","source":"http://localhost:5984/src","target":"http://localhost:5984/tgt","_replication_state":"triggered"}`
+	)
+	jsonResponse := func(status int, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header: http.Header{
+				"Content-Type": {"application/json"},
+				"ETag":         {`"1-abc"`},
+			},
+			Body: Body(body),
+		}
+	}
+	server := func(scheduler bool) func(*http.Request) (*http.Response, error) {
+		return func(req *http.Request) (*http.Response, error) {
+			switch req.Method + " " + req.URL.EscapedPath() {
+			case "HEAD /_scheduler/jobs":
+				if scheduler {
+					return jsonResponse(http.StatusOK, ""), nil
+				}
+				return jsonResponse(http.StatusNotFound, ""), nil
+			case "POST /tenantA%2F_replicator":
+				return jsonResponse(http.StatusCreated, `{"ok":true,"id":"foo","rev":"1-abc"}`), nil
+			case "GET /_scheduler/docs/tenantA%2F_replicator/foo":
+				return jsonResponse(http.StatusOK, schedulerDoc), nil
+			case "GET /_scheduler/docs/tenantA%2F_replicator":
+				return jsonResponse(http.StatusOK, `{"docs":[`+schedulerDoc+`]}`), nil
+			case "GET /tenantA%2F_replicator/foo":
+				return jsonResponse(http.StatusOK, replicatorDoc), nil
+			case "GET /tenantA%2F_replicator/_all_docs":
+				return jsonResponse(http.StatusOK, `{"rows":[{"id":"foo","doc":`+replicatorDoc+`}]}`), nil
+			case "HEAD /tenantA%2F_replicator/foo":
+				return jsonResponse(http.StatusOK, ""), nil
+			case "GET /_active_tasks":
+				return jsonResponse(http.StatusOK, `[]`), nil
+			case "DELETE /tenantA%2F_replicator/foo":
+				return jsonResponse(http.StatusOK, `{"ok":true,"id":"foo","rev":"2-def"}`), nil
+			}
+			return nil, fmt.Errorf("unexpected request: %s %s", req.Method, req.URL.EscapedPath())
+		}
+	}
+	opts := kivik.Options{OptionReplicatorDB: "tenantA/_replicator"}
+
+	for _, scheduler := range []bool{true, false} {
+		t.Run(fmt.Sprintf("scheduler %t", scheduler), func(t *testing.T) {
+			ctx := context.Background()
+			client := newTestKivikClient(t, server(scheduler))
+			rep, err := client.Replicate(ctx, "http://localhost:5984/tgt", "http://localhost:5984/src", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := rep.Update(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if err := rep.Delete(ctx); err != nil {
+				t.Fatal(err)
+			}
+			reps, err := client.GetReplications(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reps) != 1 {
+				t.Fatalf("Unexpected replications: %d", len(reps))
+			}
+			if err := reps[0].Delete(ctx); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	t.Run("invalid", func(t *testing.T) {
+		client := newTestKivikClient(t, server(true))
+		_, err := client.Replicate(context.Background(), "http://localhost:5984/tgt", "http://localhost:5984/src", kivik.Options{OptionReplicatorDB: "tenantA/replications"})
+		testy.StatusError(t, `kivik: option 'kivik:replicator-db' must be a database name ending in _replicator, not "tenantA/replications"`, http.StatusBadRequest, err)
+		_, err = client.GetReplications(context.Background(), kivik.Options{OptionReplicatorDB: 1})
+		testy.StatusError(t, "kivik: option 'kivik:replicator-db' must be a database name ending in _replicator, not 1", http.StatusBadRequest, err)
+	})
+	t.Run("transient", func(t *testing.T) {
+		client := newTestKivikClient(t, server(true))
+		_, err := client.Replicate(context.Background(), "http://localhost:5984/tgt", "http://localhost:5984/src", kivik.Options{
+			OptionReplicatorDB:         "tenantA/_replicator",
+			OptionTransientReplication: true,
+		})
+		testy.StatusError(t, "kivik: option 'kivik:replicator-db' may not be combined with 'kivik:transient-replication'", http.StatusBadRequest, err)
+	})
+}
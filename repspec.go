@@ -0,0 +1,301 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// ReplicationSpec is a typed description of a replication, as stored in the
+// _replicator database or posted to /_replicate. Use Options to convert it to
+// options for [github.com/go-kivik/kivik/v4.Client.Replicate], or Replicate to
+// start it directly. See
+// https://docs.couchdb.org/en/stable/json-structure.html#replication-settings
+type ReplicationSpec struct {
+	// ID is the replication document ID. If empty, the server generates one.
+	ID string
+
+	Source ReplicationEndpoint
+	Target ReplicationEndpoint
+
+	// At most one of Selector, DocIDs and Filter may be set. QueryParams are
+	// only valid with Filter.
+	Selector    interface{}
+	DocIDs      []string
+	Filter      string
+	QueryParams map[string]string
+
+	Continuous   bool
+	CreateTarget bool
+
+	// SinceSeq is the source sequence from which to start the replication,
+	// rather than from a checkpoint.
+	SinceSeq string
+
+	// WorkerProcesses, if greater than 0, is the number of concurrent
+	// workers.
+	WorkerProcesses int
+
+	// CheckpointInterval, if greater than 0, is the interval between
+	// checkpoints. The server's resolution is milliseconds.
+	CheckpointInterval time.Duration
+
+	// UseCheckpoints, if not nil, enables or disables checkpoints. A
+	// CheckpointInterval may not be set when checkpoints are disabled.
+	UseCheckpoints *bool
+}
+
+// ReplicationEndpoint is the source or target of a replication. At most one
+// of BasicAuth, Cookie and IAMAPIKey may be set.
+type ReplicationEndpoint struct {
+	// URL is the full URL of the database. It may not include credentials
+	// when one of the authentication fields is set.
+	URL string
+
+	// Headers are sent with every request to the endpoint.
+	Headers map[string]string
+
+	// BasicAuth authenticates with a user name and password.
+	BasicAuth *ReplicationBasicAuth
+
+	// Cookie is the value of an AuthSession cookie, as returned by the
+	// endpoint's /_session.
+	Cookie string
+
+	// IAMAPIKey is an IBM Cloud IAM API key, for IAM authentication.
+	IAMAPIKey string
+}
+
+// ReplicationBasicAuth holds the basic authentication credentials of a
+// replication endpoint.
+type ReplicationBasicAuth struct {
+	Username string
+	Password string
+}
+
+func replicationSpecError(format string, args ...interface{}) error {
+	return &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: "+format, args...)}
+}
+
+// Validate returns an error if s is incomplete, or combines incompatible
+// settings.
+func (s *ReplicationSpec) Validate() error {
+	if err := s.Source.validate("source"); err != nil {
+		return err
+	}
+	if err := s.Target.validate("target"); err != nil {
+		return err
+	}
+	var selectors []string
+	if s.Selector != nil {
+		selectors = append(selectors, "selector")
+	}
+	if len(s.DocIDs) > 0 {
+		selectors = append(selectors, "doc_ids")
+	}
+	if s.Filter != "" {
+		selectors = append(selectors, "filter")
+	}
+	if len(selectors) > 1 {
+		return replicationSpecError("%s may not be combined", strings.Join(selectors, " and "))
+	}
+	if len(s.QueryParams) > 0 && s.Filter == "" {
+		return replicationSpecError("query_params requires filter")
+	}
+	if s.WorkerProcesses < 0 {
+		return replicationSpecError("worker_processes must not be negative")
+	}
+	if s.CheckpointInterval < 0 {
+		return replicationSpecError("checkpoint_interval must not be negative")
+	}
+	if s.CheckpointInterval > 0 && s.UseCheckpoints != nil && !*s.UseCheckpoints {
+		return replicationSpecError("checkpoint_interval requires use_checkpoints")
+	}
+	return nil
+}
+
+func (e *ReplicationEndpoint) validate(name string) error {
+	if e.URL == "" {
+		return missingArg(name + " URL")
+	}
+	u, err := url.Parse(e.URL)
+	if err != nil {
+		return &kivik.Error{Status: http.StatusBadRequest, Err: err}
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return replicationSpecError("%s URL must be an http or https URL", name)
+	}
+	var auths []string
+	if u.User != nil {
+		auths = append(auths, "URL credentials")
+	}
+	if e.BasicAuth != nil {
+		auths = append(auths, "basic auth")
+	}
+	if e.Cookie != "" {
+		auths = append(auths, "cookie auth")
+	}
+	if e.IAMAPIKey != "" {
+		auths = append(auths, "IAM auth")
+	}
+	if len(auths) > 1 {
+		return replicationSpecError("%s %s may not be combined", name, strings.Join(auths, " and "))
+	}
+	if len(auths) > 0 {
+		for k := range e.Headers {
+			if strings.EqualFold(k, "Authorization") || (e.Cookie != "" && strings.EqualFold(k, "Cookie")) {
+				return replicationSpecError("%s %s header may not be combined with %s", name, k, auths[0])
+			}
+		}
+	}
+	return nil
+}
+
+// object returns the endpoint in the form of a replication document.
+func (e *ReplicationEndpoint) object() interface{} {
+	if len(e.Headers) == 0 && e.BasicAuth == nil && e.Cookie == "" && e.IAMAPIKey == "" {
+		return e.URL
+	}
+	obj := map[string]interface{}{"url": e.URL}
+	headers := make(map[string]string, len(e.Headers)+1)
+	for k, v := range e.Headers {
+		headers[k] = v
+	}
+	switch {
+	case e.BasicAuth != nil:
+		obj["auth"] = map[string]interface{}{
+			"basic": map[string]string{
+				"username": e.BasicAuth.Username,
+				"password": e.BasicAuth.Password,
+			},
+		}
+	case e.Cookie != "":
+		headers["Cookie"] = (&http.Cookie{Name: kivik.SessionCookieName, Value: e.Cookie}).String()
+	case e.IAMAPIKey != "":
+		obj["auth"] = map[string]interface{}{
+			"iam": map[string]string{"api_key": e.IAMAPIKey},
+		}
+	}
+	if len(headers) > 0 {
+		obj["headers"] = headers
+	}
+	return obj
+}
+
+// Options validates s, and returns it as options for
+// [github.com/go-kivik/kivik/v4.Client.Replicate], which override the source
+// and target passed to Replicate.
+func (s *ReplicationSpec) Options() (kivik.Options, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	opts := kivik.Options{
+		"source": s.Source.object(),
+		"target": s.Target.object(),
+	}
+	if s.ID != "" {
+		opts["_id"] = s.ID
+	}
+	switch {
+	case s.Selector != nil:
+		opts["selector"] = s.Selector
+	case len(s.DocIDs) > 0:
+		opts["doc_ids"] = s.DocIDs
+	case s.Filter != "":
+		opts["filter"] = s.Filter
+		if len(s.QueryParams) > 0 {
+			opts["query_params"] = s.QueryParams
+		}
+	}
+	if s.Continuous {
+		opts["continuous"] = true
+	}
+	if s.CreateTarget {
+		opts["create_target"] = true
+	}
+	if s.SinceSeq != "" {
+		opts["since_seq"] = s.SinceSeq
+	}
+	if s.WorkerProcesses > 0 {
+		opts["worker_processes"] = s.WorkerProcesses
+	}
+	if s.CheckpointInterval > 0 {
+		opts["checkpoint_interval"] = s.CheckpointInterval.Milliseconds()
+	}
+	if s.UseCheckpoints != nil {
+		opts["use_checkpoints"] = *s.UseCheckpoints
+	}
+	return opts, nil
+}
+
+// Replicate validates s, and starts the replication on c. options may include
+// other options for Replicate, such as OptionTransientReplication, but may not
+// override the settings of s.
+func (s *ReplicationSpec) Replicate(ctx context.Context, c *kivik.Client, options ...kivik.Options) (*kivik.Replication, error) {
+	opts, err := s.Options()
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range options {
+		for k, v := range o {
+			if _, ok := opts[k]; ok {
+				return nil, &kivik.Error{Status: http.StatusBadRequest, Err: errors.New("kivik: option '" + k + "' conflicts with the replication spec")}
+			}
+			opts[k] = v
+		}
+	}
+	return c.Replicate(ctx, s.Target.URL, s.Source.URL, opts)
+}
+
+// replicationEndpoint is the source or target of a replication document,
+// which is either a URL, or an object with a url field.
+type replicationEndpoint string
+
+func (e *replicationEndpoint) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*e = replicationEndpoint(url)
+		return nil
+	}
+	var obj struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return err
+	}
+	*e = replicationEndpoint(obj.URL)
+	return nil
+}
+
+// endpointURL returns the URL of the source or target option of a
+// replication.
+func endpointURL(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case map[string]interface{}:
+		if url, ok := t["url"].(string); ok {
+			return url
+		}
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,237 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// Revisions is the revision history of a document, as stored in its
+// _revisions field. It is returned when a document is read with the revs
+// option, and may be written with the new_edits=false option to Put or
+// BulkDocs, to store a revision along with its history, as done by the
+// replicator.
+//
+// Example:
+//
+//	doc := map[string]interface{}{
+//	    "_id":        "foo",
+//	    "_rev":       "3-c",
+//	    "_revisions": couchdb.Revisions{Start: 3, IDs: []string{"c", "b", "a"}},
+//	}
+//	_, err := db.Put(ctx, "foo", doc, kivik.Options{"new_edits": false})
+type Revisions struct {
+	// Start is the generation of the most recent revision.
+	Start int64 `json:"start"`
+
+	// IDs are the revision IDs, without generation, most recent first.
+	IDs []string `json:"ids"`
+}
+
+// Rev returns the most recent revision, or "" if IDs is empty.
+func (r *Revisions) Rev() string {
+	if len(r.IDs) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d-%s", r.Start, r.IDs[0])
+}
+
+// validate returns an error if r is not a valid history for the revision rev.
+func (r *Revisions) validate(rev string) error {
+	switch {
+	case len(r.IDs) == 0:
+		return fmt.Errorf("_revisions.ids must not be empty")
+	case int64(len(r.IDs)) > r.Start:
+		return fmt.Errorf("_revisions.ids has %d entries, more than start %d", len(r.IDs), r.Start)
+	case rev != "" && rev != r.Rev():
+		return fmt.Errorf("_rev %s does not match _revisions %s", rev, r.Rev())
+	}
+	return nil
+}
+
+// newEditsOption returns the value of the new_edits option, which defaults
+// to true. Unlike other options, it is left in opts, to be sent to the server,
+// but converted to bool, as required in a request body.
+func newEditsOption(opts map[string]interface{}) (bool, error) {
+	i, ok := opts["new_edits"]
+	if !ok {
+		return true, nil
+	}
+	switch t := i.(type) {
+	case bool:
+		return t, nil
+	case string:
+		if t == "true" || t == "false" {
+			opts["new_edits"] = t == "true"
+			return t == "true", nil
+		}
+	}
+	return false, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: option 'new_edits' must be bool, not %#v", i)}
+}
+
+// docRevisions returns the _id, _rev and _revisions fields of doc. Maps and
+// structs are inspected without marshaling them, as marshaling would consume
+// the content of any attachments.
+func docRevisions(doc interface{}) (id, rev string, revs *Revisions, err error) {
+	var fields revisionFields
+	if data, ok := rawJSON(doc); ok {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return "", "", nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: invalid document: %w", err)}
+		}
+	} else if fields, err = reflectRevisions(doc); err != nil {
+		return "", "", nil, err
+	}
+	if len(fields.Revisions) > 0 && string(fields.Revisions) != "null" {
+		revs = new(Revisions)
+		if err := json.Unmarshal(fields.Revisions, revs); err != nil {
+			return "", "", nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: invalid _revisions: %w", err)}
+		}
+	}
+	return fields.ID, fields.Rev, revs, nil
+}
+
+// revisionFields are the fields of a document which describe its revision.
+type revisionFields struct {
+	ID        string          `json:"_id"`
+	Rev       string          `json:"_rev"`
+	Revisions json.RawMessage `json:"_revisions"`
+}
+
+// rawJSON returns doc, if it is a document already encoded as JSON, which is
+// sent to the server as is.
+func rawJSON(doc interface{}) ([]byte, bool) {
+	switch t := doc.(type) {
+	case string:
+		return []byte(t), true
+	case []byte:
+		return t, true
+	case json.RawMessage:
+		return t, true
+	}
+	return nil, false
+}
+
+// reflectRevisions returns the revision fields of doc, which is not raw JSON.
+func reflectRevisions(doc interface{}) (revisionFields, error) {
+	var fields revisionFields
+	v := reflect.ValueOf(doc)
+	for v.Kind() == reflect.Ptr && !v.IsNil() && !v.Type().Implements(marshalerType) {
+		v = v.Elem()
+	}
+	var value interface{}
+	switch {
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		if f := v.MapIndex(reflect.ValueOf("_id")); f.IsValid() {
+			fields.ID, _ = f.Interface().(string)
+		}
+		if f := v.MapIndex(reflect.ValueOf("_rev")); f.IsValid() {
+			fields.Rev, _ = f.Interface().(string)
+		}
+		if f := v.MapIndex(reflect.ValueOf("_revisions")); f.IsValid() {
+			value = f.Interface()
+		}
+	case v.Kind() == reflect.Struct && !v.Type().Implements(marshalerType):
+		for i := 0; i < v.NumField(); i++ {
+			switch strings.Split(v.Type().Field(i).Tag.Get("json"), ",")[0] {
+			case "_id":
+				fields.ID, _ = v.Field(i).Interface().(string)
+			case "_rev":
+				fields.Rev, _ = v.Field(i).Interface().(string)
+			case "_revisions":
+				value = v.Field(i).Interface()
+			}
+		}
+	default:
+		// Anything else, such as a json.Marshaler, is read from its JSON
+		// form. Encoding errors are left to be reported when the request
+		// body is encoded.
+		if data, err := json.Marshal(doc); err == nil {
+			_ = json.Unmarshal(data, &fields)
+		}
+	}
+	if value != nil {
+		var err error
+		if fields.Revisions, err = json.Marshal(value); err != nil {
+			return fields, &kivik.Error{Status: http.StatusBadRequest, Err: err}
+		}
+	}
+	return fields, nil
+}
+
+var marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// validateRevisions checks the _rev and _revisions of doc, as written with
+// new_edits=false. The server then stores the revision given in _rev, so it
+// is required.
+func validateRevisions(doc interface{}) (id, rev string, err error) {
+	id, rev, revs, err := docRevisions(doc)
+	if err != nil {
+		return "", "", err
+	}
+	if rev == "" {
+		return "", "", &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: _rev required with new_edits=false")}
+	}
+	if revs != nil {
+		if err := revs.validate(rev); err != nil {
+			return "", "", &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: %w", err)}
+		}
+	}
+	return id, rev, nil
+}
+
+// newEditsResults returns one result per document of docs, for a _bulk_docs
+// request with new_edits=false. The server then reports only the documents
+// which could not be stored, if any, so the others are assumed stored at the
+// revision given in their _rev. Failures are matched to documents by ID and
+// revision, as a request may hold several revisions of a document.
+func newEditsResults(docs []driver.BulkResult, reported []bulkDocResult) []driver.BulkResult {
+	type docRev struct{ id, rev string }
+	results := make([]driver.BulkResult, len(docs))
+	index := make(map[docRev]int, len(docs))
+	for i, doc := range docs {
+		results[i] = doc
+		if _, ok := index[docRev{doc.ID, doc.Rev}]; !ok {
+			index[docRev{doc.ID, doc.Rev}] = i
+		}
+	}
+	var unmatched []driver.BulkResult
+	for _, r := range reported {
+		if r.Error == nil {
+			continue
+		}
+		i, ok := index[docRev{r.ID, r.Rev}]
+		if !ok && r.Rev == "" {
+			// Without a revision, the failure is assigned to the first
+			// revision of the document not already failed.
+			for j, result := range results {
+				if result.ID == r.ID && result.Error == nil {
+					i, ok = j, true
+					break
+				}
+			}
+		}
+		if !ok {
+			unmatched = append(unmatched, driver.BulkResult(r))
+			continue
+		}
+		results[i].Error = r.Error
+	}
+	return append(results, unmatched...)
+}
@@ -0,0 +1,169 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestDocRevisions(t *testing.T) {
+	type doc struct {
+		ID        string             `json:"_id"`
+		Rev       string             `json:"_rev,omitempty"`
+		Revisions *Revisions         `json:"_revisions,omitempty"`
+		Atts      *kivik.Attachments `json:"_attachments,omitempty"`
+	}
+	type tst struct {
+		doc     interface{}
+		id, rev string
+		revs    *Revisions
+		status  int
+		err     string
+	}
+	revs := &Revisions{Start: 2, IDs: []string{"b", "a"}}
+	tests := testy.NewTable()
+	tests.Add("map", tst{
+		doc:  map[string]interface{}{"_id": "foo", "_rev": "2-b", "_revisions": map[string]interface{}{"start": 2, "ids": []string{"b", "a"}}},
+		id:   "foo",
+		rev:  "2-b",
+		revs: revs,
+	})
+	tests.Add("struct pointer", tst{
+		doc:  &doc{ID: "foo", Rev: "2-b", Revisions: revs},
+		id:   "foo",
+		rev:  "2-b",
+		revs: revs,
+	})
+	tests.Add("struct without revisions", tst{
+		doc: doc{ID: "foo"},
+		id:  "foo",
+	})
+	tests.Add("raw JSON", tst{
+		doc:  json.RawMessage(`{"_id":"foo","_rev":"2-b","_revisions":{"start":2,"ids":["b","a"]}}`),
+		id:   "foo",
+		rev:  "2-b",
+		revs: revs,
+	})
+	tests.Add("string", tst{
+		doc:  `{"_id":"foo","_rev":"2-b","_revisions":{"start":2,"ids":["b","a"]}}`,
+		id:   "foo",
+		rev:  "2-b",
+		revs: revs,
+	})
+	tests.Add("bytes", tst{
+		doc: []byte(`{"_id":"foo","_rev":"2-b"}`),
+		id:  "foo",
+		rev: "2-b",
+	})
+	tests.Add("invalid raw JSON", tst{
+		doc:    `{"_id":`,
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid document: unexpected end of JSON input",
+	})
+	tests.Add("invalid _revisions", tst{
+		doc:    map[string]interface{}{"_id": "foo", "_revisions": "2-b"},
+		status: http.StatusBadRequest,
+		err:    "kivik: invalid _revisions: json: cannot unmarshal string",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		id, rev, revs, err := docRevisions(test.doc)
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		if id != test.id || rev != test.rev {
+			t.Errorf("Unexpected id and rev: %s, %s", id, rev)
+		}
+		if d := testy.DiffInterface(test.revs, revs); d != nil {
+			t.Error(d)
+		}
+	})
+}
+
+func TestRevisionsValidate(t *testing.T) {
+	tests := []struct {
+		revs Revisions
+		rev  string
+		err  string
+	}{
+		{revs: Revisions{Start: 3, IDs: []string{"c", "b", "a"}}, rev: "3-c"},
+		{revs: Revisions{Start: 5, IDs: []string{"e"}}, rev: "5-e"},
+		{revs: Revisions{Start: 1}, rev: "1-a", err: "_revisions.ids must not be empty"},
+		{revs: Revisions{Start: 1, IDs: []string{"b", "a"}}, rev: "1-b", err: "_revisions.ids has 2 entries, more than start 1"},
+		{revs: Revisions{Start: 2, IDs: []string{"b", "a"}}, rev: "3-b", err: "_rev 3-b does not match _revisions 2-b"},
+	}
+	for _, test := range tests {
+		err := test.revs.validate(test.rev)
+		testy.Error(t, test.err, err)
+	}
+}
+
+func TestPutNewEditsFalse(t *testing.T) {
+	type tst struct {
+		doc     interface{}
+		options map[string]interface{}
+		status  int
+		err     string
+	}
+	tests := testy.NewTable()
+	tests.Add("missing _rev", tst{
+		doc:     map[string]interface{}{"foo": "bar"},
+		options: map[string]interface{}{"new_edits": false},
+		status:  http.StatusBadRequest,
+		err:     "kivik: _rev required with new_edits=false",
+	})
+	tests.Add("inconsistent _revisions", tst{
+		doc:     map[string]interface{}{"_rev": "1-a", "_revisions": Revisions{Start: 2, IDs: []string{"b", "a"}}},
+		options: map[string]interface{}{"new_edits": false},
+		status:  http.StatusBadRequest,
+		err:     "kivik: _rev 1-a does not match _revisions 2-b",
+	})
+	tests.Add("invalid option", tst{
+		doc:     map[string]interface{}{"_rev": "1-a"},
+		options: map[string]interface{}{"new_edits": "no"},
+		status:  http.StatusBadRequest,
+		err:     `kivik: option 'new_edits' must be bool, not "no"`,
+	})
+	tests.Add("success", tst{
+		doc:     map[string]interface{}{"_rev": "2-b", "_revisions": Revisions{Start: 2, IDs: []string{"b", "a"}}},
+		options: map[string]interface{}{"new_edits": false},
+	})
+	tests.Add("raw JSON", tst{
+		doc:     `{"_rev":"2-b","_revisions":{"start":2,"ids":["b","a"]}}`,
+		options: map[string]interface{}{"new_edits": false},
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		db := newCustomDB(func(req *http.Request) (*http.Response, error) {
+			if req.URL.Query().Get("new_edits") != "false" {
+				return nil, errors.New("new_edits=false not in query")
+			}
+			return &http.Response{
+				StatusCode: http.StatusCreated,
+				Header:     http.Header{"Content-Type": {"application/json"}},
+				Body:       Body(`{"ok":true,"id":"foo","rev":"2-b"}`),
+			}, nil
+		})
+		rev, err := db.Put(context.Background(), "foo", test.doc, test.options)
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		if err == nil && rev != "2-b" {
+			t.Errorf("Unexpected rev: %s", rev)
+		}
+	})
+}
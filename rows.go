@@ -17,10 +17,13 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net/http"
 	"strings"
 	"sync/atomic"
 
+	kivik "github.com/go-kivik/kivik/v4"
 	"github.com/go-kivik/kivik/v4/driver"
 )
 
@@ -30,6 +33,9 @@ type rowsMeta struct {
 	updateSeq sequenceID
 	warning   string
 	bookmark  string
+	// executionStats, if set before parsing, receives the execution_stats
+	// of a Mango query.
+	executionStats *ExecutionStats
 }
 
 type rows struct {
@@ -117,12 +123,17 @@ func (p *bulkParser) decodeItem(i interface{}, dec *json.Decoder) error {
 	if err := dec.Decode(&result); err != nil {
 		return err
 	}
+	if len(result.Docs) == 0 {
+		return &kivik.Error{Status: http.StatusBadGateway, Err: fmt.Errorf("kivik: no docs in _bulk_get result for %q", result.ID)}
+	}
 	row.ID = result.ID
-	row.Doc = bytes.NewReader(result.Docs[0].Doc)
+	row.Doc = nil
 	row.Error = nil
 	if err := result.Docs[0].Error; err != nil {
 		row.Error = err
+		return nil
 	}
+	row.Doc = bytes.NewReader(result.Docs[0].Doc)
 	return nil
 }
 
@@ -162,6 +173,15 @@ func (r *rows) Bookmark() string {
 	return r.meta.bookmark
 }
 
+// ExecutionStats returns the execution statistics of a Mango query, if
+// requested. They are only available once iteration is complete.
+func (r *rows) ExecutionStats() *ExecutionStats {
+	if r.meta == nil {
+		return nil
+	}
+	return r.meta.executionStats
+}
+
 func (r *rows) UpdateSeq() string {
 	if r.meta == nil {
 		return ""
@@ -187,6 +207,11 @@ func (r *rowsMeta) parseMeta(key string, dec *json.Decoder) error {
 		return dec.Decode(&r.warning)
 	case "bookmark":
 		return dec.Decode(&r.bookmark)
+	case "execution_stats":
+		if r.executionStats == nil {
+			r.executionStats = &ExecutionStats{}
+		}
+		return dec.Decode(r.executionStats)
 	default:
 		// Just consume the value, since we don't know what it means.
 		var discard json.RawMessage
@@ -327,3 +352,136 @@ func (r *multiQueriesRows) Close() error {
 func (r *multiQueriesRows) QueryIndex() int {
 	return r.queryIndex
 }
+
+// pagedRows iterates over the results of a view, or _all_docs, fetching them
+// page by page. Each page is requested with limit+1; the extra row is not
+// returned, but provides the startkey and startkey_docid of the next page, so
+// that duplicate keys spanning a page boundary are handled correctly. In keys
+// mode, the keys are instead requested in batches of the page size.
+type pagedRows struct {
+	ctx   context.Context
+	query func(context.Context, map[string]interface{}) (driver.Rows, error)
+
+	// opts are the query options, excluding paging parameters.
+	opts     map[string]interface{}
+	pageSize int64
+	// limit is the number of rows remaining to be returned, or -1 for no
+	// limit.
+	limit int64
+
+	keysMode bool
+	// keys are the keys not yet requested, in keys mode.
+	keys []json.RawMessage
+
+	cur driver.Rows
+	// pageLen is the number of rows to return from the current page, and
+	// count the number returned so far.
+	pageLen, count int64
+	done           bool
+}
+
+var _ driver.Rows = &pagedRows{}
+
+// nextPage requests the page following the boundary row, or the first page
+// if boundary is nil.
+func (r *pagedRows) nextPage(boundary *driver.Row) error {
+	opts := make(map[string]interface{}, len(r.opts)+3) // nolint:gomnd
+	for k, v := range r.opts {
+		opts[k] = v
+	}
+	r.pageLen = r.pageSize
+	if r.limit >= 0 && r.limit < r.pageLen {
+		r.pageLen = r.limit
+	}
+	if r.keysMode {
+		n := r.pageSize
+		if int64(len(r.keys)) < n {
+			n = int64(len(r.keys))
+		}
+		opts["keys"] = r.keys[:n]
+		r.keys = r.keys[n:]
+		if r.limit >= 0 {
+			opts["limit"] = r.limit
+		}
+	} else {
+		opts["limit"] = r.pageLen + 1
+	}
+	if boundary != nil {
+		for _, key := range []string{"start_key", "start_key_doc_id", "skip"} {
+			delete(opts, key)
+		}
+		opts["startkey"] = json.RawMessage(boundary.Key)
+		delete(opts, "startkey_docid")
+		if boundary.ID != "" {
+			opts["startkey_docid"] = boundary.ID
+		}
+	}
+	if r.cur != nil {
+		_ = r.cur.Close()
+	}
+	cur, err := r.query(r.ctx, opts)
+	if err != nil {
+		return err
+	}
+	r.cur = cur
+	r.count = 0
+	if r.keysMode {
+		// skip applies only to the first batch of keys.
+		delete(r.opts, "skip")
+	}
+	return nil
+}
+
+func (r *pagedRows) Next(row *driver.Row) error {
+	for {
+		if r.done || r.limit == 0 {
+			return io.EOF
+		}
+		if !r.keysMode && r.count == r.pageLen {
+			if err := r.cur.Next(row); err != nil {
+				if err == io.EOF {
+					r.done = true
+				}
+				return err
+			}
+			boundary := &driver.Row{
+				ID:  row.ID,
+				Key: append(json.RawMessage(nil), row.Key...),
+			}
+			if err := r.nextPage(boundary); err != nil {
+				return err
+			}
+			continue
+		}
+		err := r.cur.Next(row)
+		switch {
+		case err == nil:
+			r.count++
+			if r.limit > 0 {
+				r.limit--
+			}
+			return nil
+		case err != io.EOF:
+			return err
+		case r.keysMode && len(r.keys) > 0:
+			if err := r.ctx.Err(); err != nil {
+				return err
+			}
+			if err := r.nextPage(nil); err != nil {
+				return err
+			}
+		default:
+			r.done = true
+			return io.EOF
+		}
+	}
+}
+
+func (r *pagedRows) Close() error {
+	return r.cur.Close()
+}
+
+// Offset, TotalRows and UpdateSeq return the values for the current page.
+func (r *pagedRows) Offset() int64     { return r.cur.Offset() }
+func (r *pagedRows) TotalRows() int64  { return r.cur.TotalRows() }
+func (r *pagedRows) UpdateSeq() string { return r.cur.UpdateSeq() }
@@ -289,3 +289,25 @@ func TestFindRowsIterator(t *testing.T) {
 		t.Errorf("Unexpected bookmark: %s", rows.Bookmark())
 	}
 }
+
+func TestFindRowsExecutionStats(t *testing.T) {
+	input := `{"docs":[{"_id":"foo"}],"bookmark":"nil","execution_stats":{"total_keys_examined":10,"total_docs_examined":5,"total_quorum_docs_examined":0,"results_returned":1,"execution_time_ms":1.5}}`
+	rows := newFindRows(context.TODO(), io.NopCloser(strings.NewReader(input))).(*rows)
+	for {
+		if err := rows.Next(&driver.Row{}); err != nil {
+			if err != io.EOF {
+				t.Fatal(err)
+			}
+			break
+		}
+	}
+	expected := &ExecutionStats{
+		TotalKeysExamined: 10,
+		TotalDocsExamined: 5,
+		ResultsReturned:   1,
+		ExecutionTimeMs:   1.5,
+	}
+	if d := testy.DiffInterface(expected, rows.ExecutionStats()); d != nil {
+		t.Error(d)
+	}
+}
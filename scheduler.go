@@ -18,9 +18,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/go-kivik/couchdb/v4/chttp"
+	kivik "github.com/go-kivik/kivik/v4"
 	"github.com/go-kivik/kivik/v4/driver"
 )
 
@@ -38,10 +40,12 @@ type schedulerDoc struct {
 
 type repInfo struct {
 	Error            error
-	DocsRead         int64 `json:"docs_read"`
-	DocsWritten      int64 `json:"docs_written"`
-	DocWriteFailures int64 `json:"doc_write_failures"`
-	Pending          int64 `json:"changes_pending"`
+	DocsRead         int64      `json:"docs_read"`
+	DocsWritten      int64      `json:"docs_written"`
+	DocWriteFailures int64      `json:"doc_write_failures"`
+	Pending          *int64     `json:"changes_pending"`
+	CheckpointedSeq  sequenceID `json:"checkpointed_source_seq"`
+	SourceSeq        sequenceID `json:"source_seq"`
 }
 
 func (i *repInfo) UnmarshalJSON(data []byte) error {
@@ -122,7 +126,7 @@ func (c *client) newSchedulerReplication(doc *schedulerDoc) *schedulerReplicatio
 	rep := &schedulerReplication{
 		db: &db{
 			client: c,
-			dbName: doc.Database,
+			dbName: url.PathEscape(doc.Database),
 		},
 	}
 	rep.setFromDoc(doc)
@@ -143,22 +147,27 @@ func (r *schedulerReplication) setFromDoc(doc *schedulerDoc) {
 	r.info = doc.Info
 }
 
-func (c *client) fetchSchedulerReplication(ctx context.Context, docID string) (*schedulerReplication, error) {
+func (c *client) fetchSchedulerReplication(ctx context.Context, dbName, docID string) (*schedulerReplication, error) {
 	rep := &schedulerReplication{
 		docID:    docID,
-		database: "_replicator",
+		database: dbName,
 		db: &db{
 			client: c,
-			dbName: "_replicator",
+			dbName: url.PathEscape(dbName),
 		},
 	}
-	for rep.source == "" {
+	b := newBackoff(100*time.Millisecond, 5*time.Second) // nolint:gomnd
+	for {
 		if err := rep.update(ctx); err != nil {
 			return rep, err
 		}
-		time.Sleep(100 * time.Millisecond) // nolint:gomnd
+		if rep.source != "" {
+			return rep, nil
+		}
+		if err := b.wait(ctx); err != nil {
+			return rep, err
+		}
 	}
-	return rep, nil
 }
 
 func (r *schedulerReplication) StartTime() time.Time { return r.startTime }
@@ -181,6 +190,11 @@ func (r *schedulerReplication) Update(ctx context.Context, rep *driver.Replicati
 	rep.DocWriteFailures = r.info.DocWriteFailures
 	rep.DocsRead = r.info.DocsRead
 	rep.DocsWritten = r.info.DocsWritten
+	if r.state == "completed" {
+		rep.Progress = 100
+	} else {
+		rep.Progress = replicationProgress(r.info.Pending, r.info.CheckpointedSeq, r.info.SourceSeq)
+	}
 	return nil
 }
 
@@ -211,7 +225,7 @@ func isBug1000(err error) bool {
 }
 
 func (r *schedulerReplication) update(ctx context.Context) error {
-	path := fmt.Sprintf("/_scheduler/docs/%s/%s", r.database, chttp.EncodeDocID(r.docID))
+	path := fmt.Sprintf("/_scheduler/docs/%s/%s", url.PathEscape(r.database), chttp.EncodeDocID(r.docID))
 	var doc schedulerDoc
 	if err := r.db.Client.DoJSON(ctx, http.MethodGet, path, nil, &doc); err != nil {
 		if isBug1000(err) {
@@ -223,7 +237,9 @@ func (r *schedulerReplication) update(ctx context.Context) error {
 	return nil
 }
 
-func (c *client) getReplicationsFromScheduler(ctx context.Context, options map[string]interface{}) ([]driver.Replication, error) {
+// getReplicationsFromScheduler lists the replications of the replicator
+// database dbName, or of all replicator databases if dbName is empty.
+func (c *client) getReplicationsFromScheduler(ctx context.Context, dbName string, options map[string]interface{}) ([]driver.Replication, error) {
 	params, err := optionsToParams(options)
 	if err != nil {
 		return nil, err
@@ -232,6 +248,9 @@ func (c *client) getReplicationsFromScheduler(ctx context.Context, options map[s
 		Docs []schedulerDoc `json:"docs"`
 	}
 	path := "/_scheduler/docs"
+	if dbName != "" {
+		path += "/" + url.PathEscape(dbName)
+	}
 	if params != nil {
 		path = path + "?" + params.Encode()
 	}
@@ -245,3 +264,120 @@ func (c *client) getReplicationsFromScheduler(ctx context.Context, options map[s
 	}
 	return reps, nil
 }
+
+// SchedulerJobs is a page of the replication jobs reported by the replication
+// scheduler.
+type SchedulerJobs struct {
+	TotalRows int64          `json:"total_rows"`
+	Offset    int64          `json:"offset"`
+	Jobs      []SchedulerJob `json:"jobs"`
+}
+
+// SchedulerJob is a replication job running under the replication scheduler.
+type SchedulerJob struct {
+	// ID is the replication ID.
+	ID string `json:"id"`
+
+	// Database and DocID identify the replication document. Both are empty
+	// for transient replications.
+	Database string `json:"database"`
+	DocID    string `json:"doc_id"`
+
+	Source    string    `json:"source"`
+	Target    string    `json:"target"`
+	User      string    `json:"user"`
+	StartTime time.Time `json:"start_time"`
+
+	// Node and PID identify the node and Erlang process running the job.
+	Node string `json:"node"`
+	PID  string `json:"pid"`
+
+	// History lists the events of the job, most recent first.
+	History []SchedulerJobEvent `json:"history"`
+
+	// Info holds the replication statistics, when reported by the server.
+	Info *SchedulerJobInfo `json:"info"`
+}
+
+// SchedulerJobEvent is an event in the history of a SchedulerJob.
+type SchedulerJobEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+
+	// Type is the type of the event, such as "added", "started", "crashed"
+	// or "stopped".
+	Type string `json:"type"`
+
+	// Reason explains crashed events.
+	Reason string `json:"reason"`
+}
+
+// SchedulerJobInfo holds the statistics of a SchedulerJob.
+type SchedulerJobInfo struct {
+	RevisionsChecked      int64  `json:"revisions_checked"`
+	MissingRevisionsFound int64  `json:"missing_revisions_found"`
+	DocsRead              int64  `json:"docs_read"`
+	DocsWritten           int64  `json:"docs_written"`
+	DocWriteFailures      int64  `json:"doc_write_failures"`
+	ChangesPending        *int64 `json:"changes_pending"`
+	CheckpointedSourceSeq string `json:"checkpointed_source_seq"`
+	SourceSeq             string `json:"source_seq"`
+	ThroughSeq            string `json:"through_seq"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface. Sequences are
+// integers on older servers.
+func (i *SchedulerJobInfo) UnmarshalJSON(data []byte) error {
+	type infoClone SchedulerJobInfo
+	var x struct {
+		*infoClone
+		CheckpointedSourceSeq sequenceID `json:"checkpointed_source_seq"`
+		SourceSeq             sequenceID `json:"source_seq"`
+		ThroughSeq            sequenceID `json:"through_seq"`
+	}
+	x.infoClone = (*infoClone)(i)
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	i.CheckpointedSourceSeq = string(x.CheckpointedSourceSeq)
+	i.SourceSeq = string(x.SourceSeq)
+	i.ThroughSeq = string(x.ThroughSeq)
+	return nil
+}
+
+// schedulerJobsOption returns the value of OptionSchedulerJobs, which is a
+// *SchedulerJobs or a *SchedulerJob, or nil if the option is not set.
+func schedulerJobsOption(opts map[string]interface{}) (interface{}, error) {
+	i, ok := opts[OptionSchedulerJobs]
+	if !ok {
+		return nil, nil
+	}
+	switch t := i.(type) {
+	case *SchedulerJobs:
+		if t != nil {
+			delete(opts, OptionSchedulerJobs)
+			return t, nil
+		}
+	case *SchedulerJob:
+		if t != nil {
+			delete(opts, OptionSchedulerJobs)
+			return t, nil
+		}
+	}
+	return nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: option '%s' must be a non-nil *SchedulerJobs or *SchedulerJob, not %T", OptionSchedulerJobs, i)}
+}
+
+// getSchedulerJobs reads a page of the scheduler's jobs into a *SchedulerJobs,
+// or a single job, by replication ID, into a *SchedulerJob.
+func (c *client) getSchedulerJobs(ctx context.Context, jobs interface{}, options map[string]interface{}) error {
+	if job, ok := jobs.(*SchedulerJob); ok {
+		if job.ID == "" {
+			return missingArg("SchedulerJob.ID")
+		}
+		return c.DoJSON(ctx, http.MethodGet, "/_scheduler/jobs/"+url.PathEscape(job.ID), nil, job)
+	}
+	query, err := optionsToParams(options)
+	if err != nil {
+		return err
+	}
+	return c.DoJSON(ctx, http.MethodGet, "/_scheduler/jobs", &chttp.Options{Query: query}, jobs)
+}
@@ -0,0 +1,273 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kivik/couchdb/v4/chttp"
+	kivik "github.com/go-kivik/kivik/v4"
+	"github.com/go-kivik/kivik/v4/driver"
+)
+
+// TransientReplicationResult is the response of the server to a transient
+// replication. See OptionTransientReplication.
+type TransientReplicationResult struct {
+	OK        bool   `json:"ok"`
+	SessionID string `json:"session_id"`
+
+	// SourceLastSeq is the last source sequence replicated.
+	SourceLastSeq string `json:"source_last_seq"`
+
+	ReplicationIDVersion int `json:"replication_id_version"`
+
+	// NoChanges is true if there was nothing to replicate.
+	NoChanges bool `json:"no_changes"`
+
+	// LocalID is the ID of a continuous replication.
+	LocalID string `json:"_local_id"`
+
+	// History lists the replication sessions, most recent first.
+	History []ReplicationHistory `json:"history"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface. Sequences are
+// integers on older servers.
+func (r *TransientReplicationResult) UnmarshalJSON(data []byte) error {
+	type resultClone TransientReplicationResult
+	var x struct {
+		*resultClone
+		SourceLastSeq sequenceID `json:"source_last_seq"`
+	}
+	x.resultClone = (*resultClone)(r)
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	r.SourceLastSeq = string(x.SourceLastSeq)
+	return nil
+}
+
+// ReplicationHistory is a session in the history of a replication.
+type ReplicationHistory struct {
+	SessionID        string    `json:"session_id"`
+	StartTime        time.Time `json:"start_time"`
+	EndTime          time.Time `json:"end_time"`
+	StartLastSeq     string    `json:"start_last_seq"`
+	EndLastSeq       string    `json:"end_last_seq"`
+	RecordedSeq      string    `json:"recorded_seq"`
+	MissingChecked   int64     `json:"missing_checked"`
+	MissingFound     int64     `json:"missing_found"`
+	DocsRead         int64     `json:"docs_read"`
+	DocsWritten      int64     `json:"docs_written"`
+	DocWriteFailures int64     `json:"doc_write_failures"`
+}
+
+// UnmarshalJSON satisfies the json.Unmarshaler interface. The server reports
+// times in RFC 1123 format, and sequences as integers on older servers.
+func (h *ReplicationHistory) UnmarshalJSON(data []byte) error {
+	type historyClone ReplicationHistory
+	var x struct {
+		*historyClone
+		StartTime    string     `json:"start_time"`
+		EndTime      string     `json:"end_time"`
+		StartLastSeq sequenceID `json:"start_last_seq"`
+		EndLastSeq   sequenceID `json:"end_last_seq"`
+		RecordedSeq  sequenceID `json:"recorded_seq"`
+	}
+	x.historyClone = (*historyClone)(h)
+	if err := json.Unmarshal(data, &x); err != nil {
+		return err
+	}
+	var err error
+	if h.StartTime, err = parseHistoryTime(x.StartTime); err != nil {
+		return err
+	}
+	if h.EndTime, err = parseHistoryTime(x.EndTime); err != nil {
+		return err
+	}
+	h.StartLastSeq = string(x.StartLastSeq)
+	h.EndLastSeq = string(x.EndLastSeq)
+	h.RecordedSeq = string(x.RecordedSeq)
+	return nil
+}
+
+// MarshalJSON satisfies the json.Marshaler interface, reporting times in RFC
+// 1123 format, as the server does.
+func (h ReplicationHistory) MarshalJSON() ([]byte, error) {
+	type historyClone ReplicationHistory
+	return json.Marshal(struct {
+		historyClone
+		StartTime string `json:"start_time"`
+		EndTime   string `json:"end_time"`
+	}{
+		historyClone: historyClone(h),
+		StartTime:    formatHistoryTime(h.StartTime),
+		EndTime:      formatHistoryTime(h.EndTime),
+	})
+}
+
+func formatHistoryTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(http.TimeFormat)
+}
+
+func parseHistoryTime(t string) (time.Time, error) {
+	if t == "" {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC1123, t)
+	return parsed.UTC(), err
+}
+
+func transientOption(opts map[string]interface{}) (bool, *TransientReplicationResult, error) {
+	i, ok := opts[OptionTransientReplication]
+	if !ok {
+		return false, nil, nil
+	}
+	delete(opts, OptionTransientReplication)
+	switch t := i.(type) {
+	case bool:
+		return t, nil, nil
+	case *TransientReplicationResult:
+		if t != nil {
+			return true, t, nil
+		}
+	}
+	return false, nil, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: option '%s' must be bool or a non-nil *TransientReplicationResult, not %T", OptionTransientReplication, i)}
+}
+
+// transientReplication is a replication started with /_replicate.
+type transientReplication struct {
+	client        *client
+	replicationID string
+	localID       string
+	source        string
+	target        string
+	startTime     time.Time
+	endTime       time.Time
+
+	// mu protects the values below
+	mu    sync.RWMutex
+	state string
+	info  driver.ReplicationInfo
+}
+
+var _ driver.Replication = &transientReplication{}
+
+func (c *client) replicateTransient(ctx context.Context, options map[string]interface{}, result *TransientReplicationResult) (*transientReplication, error) {
+	if result == nil {
+		result = new(TransientReplicationResult)
+	}
+	opts := &chttp.Options{
+		Body: chttp.EncodeBody(options),
+	}
+	start := time.Now()
+	if err := c.DoJSON(ctx, http.MethodPost, "/_replicate", opts, result); err != nil {
+		return nil, err
+	}
+	rep := &transientReplication{
+		client:    c,
+		localID:   result.LocalID,
+		source:    endpointURL(options["source"]),
+		target:    endpointURL(options["target"]),
+		startTime: start,
+		state:     string(kivik.ReplicationComplete),
+	}
+	if result.LocalID != "" {
+		rep.replicationID = strings.SplitN(result.LocalID, "+", 2)[0] // nolint:gomnd
+	}
+	if cancel, _ := options["cancel"].(bool); !cancel && result.LocalID != "" {
+		rep.state = string(kivik.ReplicationRunning)
+		return rep, nil
+	}
+	rep.endTime = time.Now()
+	if len(result.History) > 0 {
+		h := result.History[0]
+		rep.startTime, rep.endTime = h.StartTime, h.EndTime
+		rep.info = driver.ReplicationInfo{
+			DocsRead:         h.DocsRead,
+			DocsWritten:      h.DocsWritten,
+			DocWriteFailures: h.DocWriteFailures,
+		}
+	}
+	rep.info.Progress = 100
+	return rep, nil
+}
+
+func (r *transientReplication) readLock() func() {
+	r.mu.RLock()
+	return r.mu.RUnlock
+}
+
+func (r *transientReplication) ReplicationID() string { return r.replicationID }
+func (r *transientReplication) Source() string        { return r.source }
+func (r *transientReplication) Target() string        { return r.target }
+func (r *transientReplication) StartTime() time.Time  { return r.startTime }
+func (r *transientReplication) EndTime() time.Time    { defer r.readLock()(); return r.endTime }
+func (r *transientReplication) State() string         { defer r.readLock()(); return r.state }
+func (r *transientReplication) Err() error            { return nil }
+
+// Update reads the statistics of a running continuous replication from
+// _active_tasks.
+func (r *transientReplication) Update(ctx context.Context, state *driver.ReplicationInfo) error {
+	if r.State() == string(kivik.ReplicationRunning) {
+		task, err := r.client.replicationTask(ctx, r.replicationID)
+		switch {
+		case kivik.HTTPStatus(err) == http.StatusNotFound:
+			// Not yet started, or since cancelled.
+		case err != nil:
+			return err
+		default:
+			r.mu.Lock()
+			r.info = driver.ReplicationInfo{
+				DocsRead:         task.DocsRead,
+				DocsWritten:      task.DocsWritten,
+				DocWriteFailures: task.DocWriteFailures,
+				Progress:         replicationProgress(task.ChangesPending, task.CheckpointedSourceSeq, task.SourceSeq),
+			}
+			r.mu.Unlock()
+		}
+	}
+	defer r.readLock()()
+	*state = r.info
+	return nil
+}
+
+// Delete cancels a running continuous replication.
+func (r *transientReplication) Delete(ctx context.Context) error {
+	if r.State() != string(kivik.ReplicationRunning) {
+		return nil
+	}
+	opts := &chttp.Options{
+		Body: chttp.EncodeBody(map[string]interface{}{
+			"replication_id": r.localID,
+			"cancel":         true,
+		}),
+	}
+	if _, err := r.client.DoError(ctx, http.MethodPost, "/_replicate", opts); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.state = string(kivik.ReplicationComplete)
+	r.endTime = time.Now()
+	r.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,178 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"gitlab.com/flimzy/testy"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+func TestReplicateTransient(t *testing.T) {
+	jsonResponse := func(status int, body string) *http.Response {
+		return &http.Response{
+			StatusCode: status,
+			Header:     http.Header{"Content-Type": {"application/json"}},
+			Body:       Body(body),
+		}
+	}
+	checkBody := func(req *http.Request, expected string) error {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return err
+		}
+		if d := testy.DiffJSON([]byte(expected), body); d != nil {
+			return errors.New(d.String())
+		}
+		return nil
+	}
+	type tst struct {
+		fn       func(*http.Request) (*http.Response, error)
+		options  kivik.Options
+		result   *TransientReplicationResult
+		state    kivik.ReplicationState
+		docsRead int64
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("invalid option", tst{
+		options: kivik.Options{OptionTransientReplication: "yes"},
+		status:  http.StatusBadRequest,
+		err:     "kivik: option 'kivik:transient-replication' must be bool or a non-nil \\*TransientReplicationResult, not string",
+	})
+	tests.Add("server error", tst{
+		fn: func(*http.Request) (*http.Response, error) {
+			return jsonResponse(http.StatusNotFound, `{"error":"not_found","reason":"Database does not exist."}`), nil
+		},
+		options: kivik.Options{OptionTransientReplication: true},
+		status:  http.StatusNotFound,
+		err:     "Not Found",
+	})
+	tests.Add("synchronous", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if req.Method != http.MethodPost || req.URL.Path != "/_replicate" {
+				return nil, errors.New("unexpected request: " + req.Method + " " + req.URL.Path)
+			}
+			if err := checkBody(req, `{"source":"http://localhost:5984/src","target":"http://localhost:5984/tgt","create_target":true,"doc_ids":["foo"]}`); err != nil {
+				return nil, err
+			}
+			return jsonResponse(http.StatusOK, `{"history":[{"doc_write_failures":0,"docs_read":10,"docs_written":10,"end_last_seq":28,"end_time":"Sun, 11 Aug 2013 20:38:50 GMT","missing_checked":10,"missing_found":10,"recorded_seq":28,"session_id":"142a35854a08e205c47174d91b1f9628","start_last_seq":1,"start_time":"Sun, 11 Aug 2013 20:38:50 GMT"}],"ok":true,"replication_id_version":3,"session_id":"142a35854a08e205c47174d91b1f9628","source_last_seq":28}`), nil
+		},
+		options: kivik.Options{
+			OptionTransientReplication: new(TransientReplicationResult),
+			"create_target":            true,
+			"doc_ids":                  []string{"foo"},
+		},
+		result: &TransientReplicationResult{
+			OK:                   true,
+			SessionID:            "142a35854a08e205c47174d91b1f9628",
+			SourceLastSeq:        "28",
+			ReplicationIDVersion: 3,
+			History: []ReplicationHistory{
+				{
+					SessionID:      "142a35854a08e205c47174d91b1f9628",
+					StartTime:      parseTime(t, "2013-08-11T20:38:50Z"),
+					EndTime:        parseTime(t, "2013-08-11T20:38:50Z"),
+					StartLastSeq:   "1",
+					EndLastSeq:     "28",
+					RecordedSeq:    "28",
+					MissingChecked: 10,
+					MissingFound:   10,
+					DocsRead:       10,
+					DocsWritten:    10,
+				},
+			},
+		},
+		state:    kivik.ReplicationComplete,
+		docsRead: 10,
+	})
+	tests.Add("no changes", tst{
+		fn: func(*http.Request) (*http.Response, error) {
+			return jsonResponse(http.StatusOK, `{"ok":true,"no_changes":true}`), nil
+		},
+		options: kivik.Options{OptionTransientReplication: true},
+		state:   kivik.ReplicationComplete,
+	})
+	tests.Add("continuous", func(t *testing.T) interface{} {
+		var cancelled bool
+		return tst{
+			fn: func(req *http.Request) (*http.Response, error) {
+				switch {
+				case req.URL.Path == "/_active_tasks":
+					return jsonResponse(http.StatusOK, `[{"type":"replication","replication_id":"0a81b645497e6270611ec3419767a584+continuous","docs_read":5,"docs_written":5}]`), nil
+				case req.URL.Path != "/_replicate":
+					return nil, errors.New("unexpected request: " + req.URL.Path)
+				case !cancelled:
+					cancelled = true
+					return jsonResponse(http.StatusAccepted, `{"ok":true,"_local_id":"0a81b645497e6270611ec3419767a584+continuous"}`), nil
+				}
+				if err := checkBody(req, `{"replication_id":"0a81b645497e6270611ec3419767a584+continuous","cancel":true}`); err != nil {
+					return nil, err
+				}
+				return jsonResponse(http.StatusOK, `{"ok":true,"_local_id":"0a81b645497e6270611ec3419767a584+continuous"}`), nil
+			},
+			options:  kivik.Options{OptionTransientReplication: true, "continuous": true},
+			state:    kivik.ReplicationRunning,
+			docsRead: 5,
+		}
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		ctx := context.Background()
+		rep, err := newTestKivikClient(t, test.fn).Replicate(ctx, "http://localhost:5984/tgt", "http://localhost:5984/src", test.options)
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		if test.result != nil {
+			if d := testy.DiffInterface(test.result, test.options[OptionTransientReplication]); d != nil {
+				t.Errorf("Unexpected result:\n%s", d)
+			}
+		}
+		if state := rep.State(); state != test.state {
+			t.Errorf("Unexpected state: %s", state)
+		}
+		if err := rep.Update(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if docsRead := rep.DocsRead(); docsRead != test.docsRead {
+			t.Errorf("Unexpected docs read: %d", docsRead)
+		}
+		if err := rep.Delete(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if state := rep.State(); state != kivik.ReplicationComplete {
+			t.Errorf("Unexpected state after Delete: %s", state)
+		}
+		if rep.EndTime().IsZero() {
+			t.Error("Expected end time")
+		}
+	})
+}
+
+func TestReplicationHistoryUnmarshalJSON(t *testing.T) {
+	var h ReplicationHistory
+	err := h.UnmarshalJSON([]byte(`{"start_time":"not a time"}`))
+	testy.ErrorRE(t, "cannot parse", err)
+	if err := h.UnmarshalJSON([]byte(`{"start_last_seq":"1-g1AAAA","end_time":"Sun, 11 Aug 2013 20:38:50 GMT"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if h.StartLastSeq != "1-g1AAAA" || !h.EndTime.Equal(time.Date(2013, 8, 11, 20, 38, 50, 0, time.UTC)) {
+		t.Errorf("Unexpected result: %+v", h)
+	}
+}
@@ -14,6 +14,7 @@ package couchdb
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 
 	kivik "github.com/go-kivik/kivik/v4"
@@ -39,3 +40,37 @@ func deJSONify(i interface{}) (interface{}, error) {
 	}
 	return x, nil
 }
+
+// boolOption extracts the boolean option key from opts, removing it from the
+// map.
+func boolOption(opts map[string]interface{}, key string) (bool, error) {
+	i, ok := opts[key]
+	if !ok {
+		return false, nil
+	}
+	value, ok := i.(bool)
+	if !ok {
+		return false, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: option '%s' must be bool, not %T", key, i)}
+	}
+	delete(opts, key)
+	return value, nil
+}
+
+// int64Option extracts the integer option key from opts, removing it from the
+// map. ok is false if the option is not set.
+func int64Option(opts map[string]interface{}, key string) (value int64, ok bool, err error) {
+	i, ok := opts[key]
+	if !ok {
+		return 0, false, nil
+	}
+	switch t := i.(type) {
+	case int:
+		value = int64(t)
+	case int64:
+		value = t
+	default:
+		return 0, false, &kivik.Error{Status: http.StatusBadRequest, Err: fmt.Errorf("kivik: option '%s' must be int or int64, not %T", key, i)}
+	}
+	delete(opts, key)
+	return value, true, nil
+}
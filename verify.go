@@ -0,0 +1,317 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	kivik "github.com/go-kivik/kivik/v4"
+)
+
+// defaultVerifyBatchSize is the number of documents compared per request when
+// ReplicationVerifier.BatchSize is not set.
+const defaultVerifyBatchSize = 100
+
+// ReplicationVerifier compares the documents of two databases, such as the
+// source and target of a completed replication. It reads the leaf revisions
+// of every document from the changes feed of each database, and asks the
+// other database which of them it lacks, with _revs_diff.
+//
+// Example:
+//
+//	v := &couchdb.ReplicationVerifier{
+//	    Source: client.DB("src"),
+//	    Target: client.DB("tgt"),
+//	}
+//	report, err := v.Verify(ctx)
+//	if err == nil && !report.OK() {
+//	    fmt.Println("missing documents:", report.MissingDocs)
+//	}
+type ReplicationVerifier struct {
+	Source *kivik.DB
+	Target *kivik.DB
+
+	// BatchSize is the number of documents compared per request. The default
+	// is 100.
+	BatchSize int
+
+	// Attachments, if true, compares the attachment digests of the winning
+	// revisions of documents whose leaf revisions match on both sides.
+	Attachments bool
+
+	// SourceOnly, if true, only checks that the documents of Source are in
+	// Target, as for a filtered replication, and does not report extra
+	// documents and revisions.
+	SourceOnly bool
+}
+
+// VerificationReport is the result of ReplicationVerifier.Verify. Documents
+// and revisions are listed in ID order.
+type VerificationReport struct {
+	// SourceDocs and TargetDocs are the numbers of documents checked in each
+	// database, including deleted documents.
+	SourceDocs int64
+	TargetDocs int64
+
+	// MissingDocs are the documents of Source, none of whose leaf revisions
+	// are in Target.
+	MissingDocs []string
+
+	// MissingRevs are the leaf revisions of Source which are not in Target,
+	// by document ID, for documents not listed in MissingDocs.
+	MissingRevs map[string][]string
+
+	// ExtraDocs and ExtraRevs are the documents and revisions of Target which
+	// are not in Source.
+	ExtraDocs []string
+	ExtraRevs map[string][]string
+
+	// AttachmentMismatches lists the attachments whose digests differ.
+	AttachmentMismatches []AttachmentMismatch
+}
+
+// AttachmentMismatch is an attachment which differs between the source and
+// target of a verification. A digest is empty if the attachment is missing
+// from that side.
+type AttachmentMismatch struct {
+	DocID        string
+	Rev          string
+	Filename     string
+	SourceDigest string
+	TargetDigest string
+}
+
+// OK returns true if no differences were found.
+func (r *VerificationReport) OK() bool {
+	return len(r.MissingDocs) == 0 && len(r.MissingRevs) == 0 &&
+		len(r.ExtraDocs) == 0 && len(r.ExtraRevs) == 0 &&
+		len(r.AttachmentMismatches) == 0
+}
+
+// Verify compares Source and Target, and reports their differences. The
+// comparison is not atomic; documents written to either database during the
+// comparison may be reported as differences.
+func (v *ReplicationVerifier) Verify(ctx context.Context) (*VerificationReport, error) {
+	if v.Source == nil {
+		return nil, missingArg("Source")
+	}
+	if v.Target == nil {
+		return nil, missingArg("Target")
+	}
+	report := &VerificationReport{
+		MissingRevs: map[string][]string{},
+		ExtraRevs:   map[string][]string{},
+	}
+	var err error
+	report.SourceDocs, err = v.compare(ctx, v.Source, v.Target, func(batch map[string][]string, missing map[string][]string) error {
+		report.MissingDocs = append(report.MissingDocs, collectMissing(batch, missing, report.MissingRevs)...)
+		if !v.Attachments {
+			return nil
+		}
+		return v.compareAttachments(ctx, batch, missing, report)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !v.SourceOnly {
+		report.TargetDocs, err = v.compare(ctx, v.Target, v.Source, func(batch map[string][]string, missing map[string][]string) error {
+			report.ExtraDocs = append(report.ExtraDocs, collectMissing(batch, missing, report.ExtraRevs)...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(report.MissingDocs)
+	sort.Strings(report.ExtraDocs)
+	sort.Slice(report.AttachmentMismatches, func(i, j int) bool {
+		a, b := report.AttachmentMismatches[i], report.AttachmentMismatches[j]
+		if a.DocID != b.DocID {
+			return a.DocID < b.DocID
+		}
+		return a.Filename < b.Filename
+	})
+	return report, nil
+}
+
+// compare reads the leaf revisions of every document in from, in batches, and
+// calls fn with each batch and the revisions of the batch missing from to.
+func (v *ReplicationVerifier) compare(ctx context.Context, from, to *kivik.DB, fn func(batch, missing map[string][]string) error) (int64, error) {
+	batchSize := v.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultVerifyBatchSize
+	}
+	changes := from.Changes(ctx, kivik.Options{"style": "all_docs"})
+	defer changes.Close() // nolint:errcheck
+	var count int64
+	batch := make(map[string][]string, batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		missing, err := revsDiffMissing(ctx, to, batch)
+		if err != nil {
+			return err
+		}
+		if err := fn(batch, missing); err != nil {
+			return err
+		}
+		batch = make(map[string][]string, batchSize)
+		return nil
+	}
+	for changes.Next() {
+		id := changes.ID()
+		if _, ok := batch[id]; !ok {
+			count++
+		}
+		// A document may appear more than once in a feed read from a
+		// clustered database; the later entry is the more recent.
+		batch[id] = changes.Changes()
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	if err := changes.Err(); err != nil {
+		return 0, err
+	}
+	if err := flush(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+type revsDiff struct {
+	Missing []string `json:"missing"`
+}
+
+// revsDiffMissing returns the revisions of revMap which are missing from db,
+// by document ID.
+func revsDiffMissing(ctx context.Context, db *kivik.DB, revMap map[string][]string) (map[string][]string, error) {
+	rows := db.RevsDiff(ctx, revMap)
+	defer rows.Close() // nolint:errcheck
+	missing := map[string][]string{}
+	for rows.Next() {
+		id, err := rows.ID()
+		if err != nil {
+			return nil, err
+		}
+		var diff revsDiff
+		if err := rows.ScanValue(&diff); err != nil {
+			return nil, err
+		}
+		if len(diff.Missing) > 0 {
+			missing[id] = diff.Missing
+		}
+	}
+	return missing, rows.Err()
+}
+
+// collectMissing adds the missing revisions of documents of which some
+// revisions are present to revs, and returns the documents of which all
+// revisions are missing.
+func collectMissing(batch, missing, revs map[string][]string) []string {
+	var docs []string
+	for id, missingRevs := range missing {
+		if len(missingRevs) >= len(batch[id]) {
+			docs = append(docs, id)
+			continue
+		}
+		sort.Strings(missingRevs)
+		revs[id] = missingRevs
+	}
+	return docs
+}
+
+type attachmentDigests struct {
+	ID          string `json:"_id"`
+	Rev         string `json:"_rev"`
+	Attachments map[string]struct {
+		Digest string `json:"digest"`
+	} `json:"_attachments"`
+}
+
+// compareAttachments compares the attachment digests of the documents of
+// batch which have no missing revisions.
+func (v *ReplicationVerifier) compareAttachments(ctx context.Context, batch, missing map[string][]string, report *VerificationReport) error {
+	ids := make([]string, 0, len(batch))
+	for id := range batch {
+		if _, ok := missing[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+	source, err := winningDigests(ctx, v.Source, ids)
+	if err != nil {
+		return err
+	}
+	target, err := winningDigests(ctx, v.Target, ids)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		src, tgt := source[id], target[id]
+		if src == nil || tgt == nil || src.Rev != tgt.Rev {
+			// Deleted, or the winning revisions differ, which is reported
+			// as extra revisions.
+			continue
+		}
+		names := map[string]struct{}{}
+		for name := range src.Attachments {
+			names[name] = struct{}{}
+		}
+		for name := range tgt.Attachments {
+			names[name] = struct{}{}
+		}
+		for name := range names {
+			srcDigest, tgtDigest := src.Attachments[name].Digest, tgt.Attachments[name].Digest
+			if srcDigest != tgtDigest {
+				report.AttachmentMismatches = append(report.AttachmentMismatches, AttachmentMismatch{
+					DocID:        id,
+					Rev:          src.Rev,
+					Filename:     name,
+					SourceDigest: srcDigest,
+					TargetDigest: tgtDigest,
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// winningDigests returns the attachment digests of the winning revisions of
+// the documents ids in db. Deleted and missing documents are omitted.
+func winningDigests(ctx context.Context, db *kivik.DB, ids []string) (map[string]*attachmentDigests, error) {
+	rows := db.AllDocs(ctx, kivik.Options{"keys": ids, "include_docs": true})
+	defer rows.Close() // nolint:errcheck
+	result := make(map[string]*attachmentDigests, len(ids))
+	for rows.Next() {
+		doc := new(attachmentDigests)
+		if err := rows.ScanDoc(doc); err != nil {
+			if kivik.HTTPStatus(err) == http.StatusNotFound {
+				continue
+			}
+			return nil, err
+		}
+		if doc.ID != "" {
+			result[doc.ID] = doc
+		}
+	}
+	return result, rows.Err()
+}
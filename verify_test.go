@@ -0,0 +1,168 @@
+// Licensed under the Apache License, Version 2.0 (the "License"); you may not
+// use this file except in compliance with the License. You may obtain a copy of
+// the License at
+//
+//  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations under
+// the License.
+
+package couchdb
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"gitlab.com/flimzy/testy"
+)
+
+func TestReplicationVerifierVerify(t *testing.T) {
+	type tst struct {
+		server   *fakeCouch
+		fn       func(*http.Request) (*http.Response, error)
+		verifier ReplicationVerifier
+		expected *VerificationReport
+		ok       bool
+		status   int
+		err      string
+	}
+	tests := testy.NewTable()
+	tests.Add("missing source", tst{
+		verifier: ReplicationVerifier{},
+		status:   http.StatusBadRequest,
+		err:      "kivik: Source required",
+	})
+	tests.Add("identical", tst{
+		server: &fakeCouch{dbs: map[string]*fakeDB{
+			"src": {docs: map[string]*fakeDoc{
+				"a": {leaves: []string{"1-a"}},
+				"b": {leaves: []string{"2-b", "2-c"}},
+				"c": {leaves: []string{"3-c"}, deleted: true},
+			}},
+			"tgt": {docs: map[string]*fakeDoc{
+				"a": {leaves: []string{"1-a"}},
+				"b": {leaves: []string{"2-b", "2-c"}},
+				"c": {leaves: []string{"3-c"}, deleted: true},
+			}},
+		}},
+		verifier: ReplicationVerifier{BatchSize: 2, Attachments: true},
+		expected: &VerificationReport{
+			SourceDocs:  3,
+			TargetDocs:  3,
+			MissingRevs: map[string][]string{},
+			ExtraRevs:   map[string][]string{},
+		},
+		ok: true,
+	})
+	tests.Add("differences", tst{
+		server: &fakeCouch{dbs: map[string]*fakeDB{
+			"src": {docs: map[string]*fakeDoc{
+				"a": {leaves: []string{"1-a"}},
+				"b": {leaves: []string{"2-b", "2-c"}},
+				"d": {leaves: []string{"1-d"}},
+			}},
+			"tgt": {docs: map[string]*fakeDoc{
+				"a": {leaves: []string{"1-a"}},
+				"b": {leaves: []string{"2-b"}},
+				"e": {leaves: []string{"1-e"}},
+			}},
+		}},
+		verifier: ReplicationVerifier{BatchSize: 2},
+		expected: &VerificationReport{
+			SourceDocs:  3,
+			TargetDocs:  3,
+			MissingDocs: []string{"d"},
+			MissingRevs: map[string][]string{"b": {"2-c"}},
+			ExtraDocs:   []string{"e"},
+			ExtraRevs:   map[string][]string{},
+		},
+	})
+	tests.Add("source only", tst{
+		server: &fakeCouch{dbs: map[string]*fakeDB{
+			"src": {docs: map[string]*fakeDoc{
+				"a": {leaves: []string{"1-a"}},
+			}},
+			"tgt": {docs: map[string]*fakeDoc{
+				"a": {leaves: []string{"1-a"}},
+				"e": {leaves: []string{"1-e"}},
+			}},
+		}},
+		verifier: ReplicationVerifier{SourceOnly: true},
+		expected: &VerificationReport{
+			SourceDocs:  1,
+			MissingRevs: map[string][]string{},
+			ExtraRevs:   map[string][]string{},
+		},
+		ok: true,
+	})
+	tests.Add("attachments", tst{
+		server: &fakeCouch{dbs: map[string]*fakeDB{
+			"src": {docs: map[string]*fakeDoc{
+				"a": {leaves: []string{"1-a"}, attachments: map[string]string{"foo.txt": "md5-abc", "bar.txt": "md5-def"}},
+				"b": {leaves: []string{"1-b"}, attachments: map[string]string{"foo.txt": "md5-abc"}},
+				"c": {leaves: []string{"1-c"}, attachments: map[string]string{"foo.txt": "md5-abc"}},
+			}},
+			"tgt": {docs: map[string]*fakeDoc{
+				"a": {leaves: []string{"1-a"}, attachments: map[string]string{"foo.txt": "md5-xyz"}},
+				"b": {leaves: []string{"1-b"}, attachments: map[string]string{"foo.txt": "md5-abc"}},
+			}},
+		}},
+		verifier: ReplicationVerifier{Attachments: true},
+		expected: &VerificationReport{
+			SourceDocs:  3,
+			TargetDocs:  2,
+			MissingDocs: []string{"c"},
+			MissingRevs: map[string][]string{},
+			ExtraRevs:   map[string][]string{},
+			AttachmentMismatches: []AttachmentMismatch{
+				{DocID: "a", Rev: "1-a", Filename: "bar.txt", SourceDigest: "md5-def"},
+				{DocID: "a", Rev: "1-a", Filename: "foo.txt", SourceDigest: "md5-abc", TargetDigest: "md5-xyz"},
+			},
+		},
+	})
+	tests.Add("revs_diff error", tst{
+		fn: func(req *http.Request) (*http.Response, error) {
+			if strings.HasSuffix(req.URL.Path, "/_revs_diff") {
+				return nil, errors.New("revs_diff failed")
+			}
+			return (&fakeCouch{dbs: map[string]*fakeDB{
+				"src": {docs: fakeLeaves(map[string][]string{"a": {"1-a"}})},
+			}}).handle(req)
+		},
+		status: http.StatusBadGateway,
+		err:    "revs_diff failed",
+	})
+
+	tests.Run(t, func(t *testing.T, test tst) {
+		fn := test.fn
+		if fn == nil {
+			fn = test.server.handle
+		}
+		if test.verifier.Source == nil && test.err != "kivik: Source required" {
+			client := newTestKivikClient(t, fn)
+			test.verifier.Source = client.DB("src")
+			test.verifier.Target = client.DB("tgt")
+		}
+		report, err := test.verifier.Verify(context.Background())
+		testy.StatusErrorRE(t, test.err, test.status, err)
+		if d := testy.DiffInterface(test.expected, report); d != nil {
+			t.Error(d)
+		}
+		if report != nil && report.OK() != test.ok {
+			t.Errorf("Unexpected OK: %t", report.OK())
+		}
+	})
+}
+
+func TestReplicationVerifierMissingTarget(t *testing.T) {
+	client := newTestKivikClient(t, nil)
+	v := &ReplicationVerifier{Source: client.DB("src")}
+	_, err := v.Verify(context.Background())
+	testy.StatusError(t, "kivik: Target required", http.StatusBadRequest, err)
+}